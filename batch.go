@@ -0,0 +1,192 @@
+package ticktick
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/carlmjohnson/requests"
+)
+
+// the response of the /batch endpoints
+type batchResponse struct {
+	Id2Etag  map[string]string          `json:"id2etag"`
+	Id2Error map[string]json.RawMessage `json:"id2error"`
+}
+
+// ItemError is the error reported by a batch endpoint for one of the items
+type ItemError struct {
+	Id      string
+	Message string
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %v failed: %v", e.Id, e.Message)
+}
+
+// the error of the item with the id, nil if it succeeded
+func (r *batchResponse) itemError(id string) error {
+	raw, ok := r.Id2Error[id]
+	if !ok {
+		return nil
+	}
+	var message string
+	if err := json.Unmarshal(raw, &message); err != nil {
+		message = string(raw)
+	}
+	return &ItemError{Id: id, Message: message}
+}
+
+// send the add, update or delete of a single item to a batch endpoint, only the adds are not retried
+func (c *Client) batchItem(ctx context.Context, endpoint string, action string, item any, id string) error {
+	body := map[string]any{action: []any{item}}
+	var resp batchResponse
+	send := c.fetchIdempotent
+	if action == "add" {
+		send = c.fetch
+	}
+	if err := send(ctx, func() *requests.Builder {
+		return c.request(endpoint).
+			BodyJSON(body).
+			ToJSON(&resp)
+	}); err != nil {
+		return err
+	}
+	return resp.itemError(id)
+}
+
+const (
+	// the tasks sent in one request to /batch/task, larger batches are split into chunks
+	batchTaskLimit = 50
+)
+
+// BatchRequest holds the tasks to add, update and delete in one go. The added tasks
+// without an id get one generated, and only the ids of the deleted tasks are used.
+type BatchRequest struct {
+	Add    []TaskItem
+	Update []TaskItem
+	Delete []TaskItem
+}
+
+// BatchTaskResult is the outcome for one task of a batch, Err is an *ItemError if the server refused it
+type BatchTaskResult struct {
+	Task TaskItem
+	Etag string
+	Err  error
+}
+
+// BatchResult has the results of every task in the order of the request,
+// and the merged id2etag and id2error maps of all the chunks
+type BatchResult struct {
+	Added    []BatchTaskResult
+	Updated  []BatchTaskResult
+	Deleted  []BatchTaskResult
+	Id2Etag  map[string]string
+	Id2Error map[string]string
+}
+
+// the body of a /batch/task request
+type batchTaskBody struct {
+	Add    []TaskItem        `json:"add"`
+	Update []TaskItem        `json:"update"`
+	Delete []batchTaskDelete `json:"delete"`
+
+	deleted []TaskItem // the full tasks behind Delete
+}
+
+type batchTaskDelete struct {
+	ProjectId string `json:"projectId"`
+	TaskId    string `json:"taskId"`
+}
+
+// Add, update and delete tasks with as few requests as possible. If a request fails,
+// the results of the chunks sent before it are returned along with the error.
+func (c *Client) BatchTasks(req BatchRequest) (*BatchResult, error) {
+	return c.BatchTasksCtx(context.Background(), req)
+}
+
+// BatchTasksCtx is BatchTasks with a context
+func (c *Client) BatchTasksCtx(ctx context.Context, req BatchRequest) (*BatchResult, error) {
+	add := make([]TaskItem, len(req.Add))
+	for i, t := range req.Add {
+		if t.Id == "" {
+			t.Id = newObjectId()
+		}
+		add[i] = t
+	}
+	for _, t := range req.Update {
+		if t.Id == "" {
+			return nil, fmt.Errorf("%w: task Id is empty", ErrTaskNotCreated)
+		}
+	}
+	for _, t := range req.Delete {
+		if t.Id == "" {
+			return nil, fmt.Errorf("the %w, thus not deleted", ErrTaskNotCreated)
+		}
+	}
+
+	result := &BatchResult{Id2Etag: make(map[string]string), Id2Error: make(map[string]string)}
+	var chunks []batchTaskBody
+	var chunk batchTaskBody
+	size := 0
+	push := func(f func()) {
+		if size == batchTaskLimit {
+			chunks = append(chunks, chunk)
+			chunk = batchTaskBody{}
+			size = 0
+		}
+		f()
+		size++
+	}
+	for _, t := range add {
+		push(func() { chunk.Add = append(chunk.Add, t) })
+	}
+	for _, t := range req.Update {
+		push(func() { chunk.Update = append(chunk.Update, t) })
+	}
+	for _, t := range req.Delete {
+		push(func() {
+			chunk.Delete = append(chunk.Delete, batchTaskDelete{ProjectId: t.ProjectId, TaskId: t.Id})
+			chunk.deleted = append(chunk.deleted, t)
+		})
+	}
+	if size > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	for _, body := range chunks {
+		var resp batchResponse
+		send := c.fetchIdempotent
+		if len(body.Add) > 0 {
+			send = c.fetch
+		}
+		if err := send(ctx, func() *requests.Builder {
+			return c.request(taskDeleteUrlEndpoint).
+				BodyJSON(&body).
+				ToJSON(&resp)
+		}); err != nil {
+			return result, err
+		}
+
+		for id, etag := range resp.Id2Etag {
+			result.Id2Etag[id] = etag
+		}
+		for id := range resp.Id2Error {
+			result.Id2Error[id] = resp.itemError(id).(*ItemError).Message
+		}
+		taskResult := func(t TaskItem) BatchTaskResult {
+			t.ProjectName = c.projectName(t.ProjectId)
+			return BatchTaskResult{Task: t, Etag: resp.Id2Etag[t.Id], Err: resp.itemError(t.Id)}
+		}
+		for _, t := range body.Add {
+			result.Added = append(result.Added, taskResult(t))
+		}
+		for _, t := range body.Update {
+			result.Updated = append(result.Updated, taskResult(t))
+		}
+		for _, t := range body.deleted {
+			result.Deleted = append(result.Deleted, taskResult(t))
+		}
+	}
+	return result, nil
+}
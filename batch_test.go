@@ -0,0 +1,129 @@
+package ticktick
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestBatchItemError(t *testing.T) {
+	assert := assert.New(t)
+	var resp batchResponse
+	json.Unmarshal([]byte(`{"id2etag":{"1":"etag1"},"id2error":{"2":"EXCEED_QUOTA","3":{"code":"unknown"}}}`), &resp)
+
+	assert.Nil(resp.itemError("1"))
+	assert.Equal(&ItemError{Id: "2", Message: "EXCEED_QUOTA"}, resp.itemError("2"))
+	assert.Equal(&ItemError{Id: "3", Message: `{"code":"unknown"}`}, resp.itemError("3"))
+	assert.Equal("item 2 failed: EXCEED_QUOTA", resp.itemError("2").Error())
+}
+
+func TestBatchTasks(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+	// the batches are answered by a transport, which sees the bodies of every chunk
+	gock.Off()
+
+	// 60 adds, 30 updates and 10 deletes are sent as 2 chunks
+	var req BatchRequest
+	for i := 0; i < 60; i++ {
+		req.Add = append(req.Add, TaskItem{Title: fmt.Sprint("add", i), ProjectId: "pid1"})
+	}
+	for i := 0; i < 30; i++ {
+		req.Update = append(req.Update, TaskItem{Id: fmt.Sprint("update", i), Title: "updated", ProjectId: "pid2"})
+	}
+	for i := 0; i < 10; i++ {
+		req.Delete = append(req.Delete, TaskItem{Id: fmt.Sprint("delete", i), Title: "deleted", ProjectId: "pid1"})
+	}
+
+	var bodies []batchTaskBody
+	replyBatch := func(r *http.Request) (*http.Response, error) {
+		var body batchTaskBody
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		bodies = append(bodies, body)
+		id2etag := make(map[string]string)
+		id2error := make(map[string]string)
+		for _, t := range body.Add {
+			id2etag[t.Id] = "etag-" + t.Id
+		}
+		for _, t := range body.Update {
+			if t.Id == "update7" {
+				id2error[t.Id] = "TASK_NOT_FOUND"
+			} else {
+				id2etag[t.Id] = "etag-" + t.Id
+			}
+		}
+		resp, _ := json.Marshal(map[string]any{"id2etag": id2etag, "id2error": id2error})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(resp)), Header: http.Header{}}, nil
+	}
+	client.httpClient = &http.Client{Transport: roundTripFunc(replyBatch)}
+
+	result, err := client.BatchTasks(req)
+	assert.Nil(err)
+	if assert.Len(bodies, 2) {
+		assert.Len(bodies[0].Add, 50)
+		assert.Len(bodies[1].Add, 10)
+		assert.Len(bodies[1].Update, 30)
+		assert.Len(bodies[1].Delete, 10)
+		assert.Equal(batchTaskDelete{ProjectId: "pid1", TaskId: "delete0"}, bodies[1].Delete[0])
+	}
+
+	// the results, in the order of the request
+	assert.Len(result.Added, 60)
+	assert.Len(result.Updated, 30)
+	assert.Len(result.Deleted, 10)
+	for i, r := range result.Added {
+		assert.Equal(fmt.Sprint("add", i), r.Task.Title)
+		assert.Len(r.Task.Id, 24)
+		assert.Equal("pname1", r.Task.ProjectName)
+		assert.Equal("etag-"+r.Task.Id, r.Etag)
+		assert.Nil(r.Err)
+	}
+	assert.Equal("pname2", result.Updated[0].Task.ProjectName)
+	var itemErr *ItemError
+	if assert.True(errors.As(result.Updated[7].Err, &itemErr)) {
+		assert.Equal("TASK_NOT_FOUND", itemErr.Message)
+	}
+	assert.Equal("deleted", result.Deleted[0].Task.Title)
+	assert.Len(result.Id2Etag, 89)
+	assert.Equal(map[string]string{"update7": "TASK_NOT_FOUND"}, result.Id2Error)
+
+	// a failed chunk returns the results so far
+	calls := 0
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 2 {
+			return &http.Response{StatusCode: 500, Body: io.NopCloser(bytes.NewReader(nil)), Header: http.Header{}}, nil
+		}
+		return replyBatch(r)
+	})}
+	result, err = client.BatchTasks(req)
+	assert.NotNil(err)
+	assert.Len(result.Added, 50)
+	assert.Empty(result.Updated)
+
+	// tasks that are not created yet
+	_, err = client.BatchTasks(BatchRequest{Update: []TaskItem{{Title: "test"}}})
+	assert.True(errors.Is(err, ErrTaskNotCreated))
+	_, err = client.BatchTasks(BatchRequest{Delete: []TaskItem{{Title: "test"}}})
+	assert.True(errors.Is(err, ErrTaskNotCreated))
+
+	// nothing to do
+	result, err = client.BatchTasks(BatchRequest{})
+	assert.Nil(err)
+	assert.Empty(result.Added)
+}
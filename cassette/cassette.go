@@ -0,0 +1,351 @@
+// Package cassette records the http traffic of a client to a file, and replays it in the tests.
+//
+// Record once against the real server, the credentials and the tokens are redacted:
+//
+//	rec := cassette.NewRecorder("testdata/flow.json", http.DefaultTransport, username, password)
+//	client, err := ticktick.NewClientWithOptions(ticktick.WithCredentials(username, password), ticktick.WithTransport(rec))
+//
+// Then replay it without network, the requests that are not in the cassette fail:
+//
+//	rep, err := cassette.NewReplayer("testdata/flow.json")
+//	client, err := ticktick.NewClientWithOptions(ticktick.WithCredentials("user", "pass"), ticktick.WithTransport(rep))
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// the replacement of the secrets
+const Redacted = "REDACTED"
+
+// the replayed request is not in the cassette, or all its recordings are used
+var ErrUnmatched = errors.New("no recorded interaction matches the request")
+
+// the json keys, headers and query parameters whose values are always redacted
+var (
+	redactedKeys    = map[string]bool{"token": true, "password": true, "username": true, "email": true, "phone": true}
+	redactedHeaders = []string{"Cookie", "Set-Cookie", "Authorization"}
+)
+
+// Cassette is a list of recorded request and response pairs
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    Body        `json:"body"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       Body        `json:"body"`
+}
+
+// Body is kept as json when it is valid json, for readable cassettes, else as a string
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if len(b) == 0 {
+		return []byte("null"), nil
+	}
+	if json.Valid(b) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, b); err == nil {
+			return json.Marshal(map[string]json.RawMessage{"json": buf.Bytes()})
+		}
+	}
+	return json.Marshal(map[string]string{"text": string(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var v struct {
+		JSON json.RawMessage `json:"json"`
+		Text string          `json:"text"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.JSON != nil {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v.JSON); err != nil {
+			return err
+		}
+		*b = Body(buf.Bytes())
+	} else if v.Text != "" {
+		*b = Body(v.Text)
+	} else {
+		*b = nil
+	}
+	return nil
+}
+
+// Load a cassette file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette %v is corrupted: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save the cassette to a file
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Recorder is a http.RoundTripper sending the requests with another one,
+// and saving the redacted requests and responses to a cassette file after every request
+type Recorder struct {
+	path    string
+	next    http.RoundTripper
+	secrets []string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// Record to the file at path, the secrets (like the username and the password) are redacted
+// wherever they appear, on top of the tokens, cookies and credential fields
+func NewRecorder(path string, next http.RoundTripper, secrets ...string) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	var nonEmpty []string
+	for _, s := range secrets {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return &Recorder{path: path, next: next, secrets: nonEmpty}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     redactURL(req.URL, r.secrets),
+			Headers: redactHeaders(req.Header, r.secrets),
+			Body:    redactBody(reqBody, r.secrets),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    redactHeaders(resp.Header, r.secrets),
+			Body:       redactBody(respBody, r.secrets),
+		},
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.cassette.Save(r.path); err != nil {
+		return nil, fmt.Errorf("failed to save the cassette: %w", err)
+	}
+	return resp, nil
+}
+
+// Replayer is a http.RoundTripper answering the requests with the responses of a cassette.
+// A request matches a recording with the same method, url and body after redaction,
+// the recordings are used once each and in order.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// Replay the cassette file at path
+func NewReplayer(path string) (*Replayer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{cassette: c, used: make([]bool, len(c.Interactions))}, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	method, u, body := req.Method, redactURL(req.URL, nil), redactBody(reqBody, nil)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		recorded := interaction.Request
+		if r.used[i] || recorded.Method != method || recorded.URL != u || !sameBody(recorded.Body, body) {
+			continue
+		}
+		r.used[i] = true
+		resp := interaction.Response
+		return &http.Response{
+			StatusCode:    resp.StatusCode,
+			Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        resp.Headers.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+			ContentLength: int64(len(resp.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %v %v %s", ErrUnmatched, method, u, body)
+}
+
+// the recordings that were not replayed
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.cassette.Interactions[i])
+		}
+	}
+	return unused
+}
+
+// read the body and put it back for the next reader
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// the json bodies are compared by value, a hand-written cassette can be indented or ordered differently
+func sameBody(a, b []byte) bool {
+	va, errA := decodeJSON(a)
+	vb, errB := decodeJSON(b)
+	if errA == nil && errB == nil {
+		return reflect.DeepEqual(va, vb)
+	}
+	return bytes.Equal(a, b)
+}
+
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("trailing data")
+	}
+	return v, nil
+}
+
+func redactString(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+func redactURL(u *url.URL, secrets []string) string {
+	redacted := *u
+	query := redacted.Query()
+	for key := range query {
+		if redactedKeys[strings.ToLower(key)] {
+			query.Set(key, Redacted)
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	redacted.User = nil
+	return redactString(redacted.String(), secrets)
+}
+
+func redactHeaders(h http.Header, secrets []string) http.Header {
+	redacted := make(http.Header, len(h))
+	for key, values := range h {
+		for _, v := range values {
+			redacted.Add(key, redactString(v, secrets))
+		}
+	}
+	for _, key := range redactedHeaders {
+		if redacted.Get(key) != "" {
+			redacted.Set(key, Redacted)
+		}
+	}
+	if len(redacted) == 0 {
+		return nil
+	}
+	return redacted
+}
+
+// the values of the credential keys are redacted in json bodies, then the secrets everywhere
+func redactBody(body []byte, secrets []string) Body {
+	if len(body) == 0 {
+		return nil
+	}
+	if v, err := decodeJSON(body); err == nil {
+		if data, err := json.Marshal(redactJSON(v)); err == nil {
+			body = data
+		}
+	}
+	return Body(redactString(string(body), secrets))
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if _, isString := value.(string); isString && redactedKeys[strings.ToLower(key)] {
+				v[key] = Redacted
+			} else {
+				v[key] = redactJSON(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,130 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func NewTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/user/signon":
+			http.SetCookie(w, &http.Cookie{Name: "t", Value: "secrettoken"})
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"token": "secrettoken", "userId": 42, "username": "alice@example.com", "inboxId": "inbox42"}`)
+		case "/echo":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusTeapot)
+			w.Write(body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewTestServer()
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// record
+	rec := NewRecorder(path, nil, "alice@example.com", "s3cret", "")
+	client := &http.Client{Transport: rec}
+	resp, err := client.Post(srv.URL+"/user/signon?wc=true", "application/json", strings.NewReader(`{"username": "alice@example.com", "password": "s3cret"}`))
+	assert.Nil(err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(string(body), "secrettoken")
+
+	req, _ := http.NewRequest("POST", srv.URL+"/echo?token=abc", strings.NewReader("hello alice@example.com"))
+	req.Header.Set("Cookie", "t=secrettoken")
+	resp, err = client.Do(req)
+	assert.Nil(err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal("hello alice@example.com", string(body))
+
+	// the secrets are not on disk
+	data, err := os.ReadFile(path)
+	assert.Nil(err)
+	for _, secret := range []string{"alice@example.com", "s3cret", "secrettoken", "abc"} {
+		assert.NotContains(string(data), secret)
+	}
+	c, err := Load(path)
+	assert.Nil(err)
+	if assert.Len(c.Interactions, 2) {
+		assert.JSONEq(`{"password": "REDACTED", "username": "REDACTED"}`, string(c.Interactions[0].Request.Body))
+		assert.JSONEq(`{"token": "REDACTED", "userId": 42, "username": "REDACTED", "inboxId": "inbox42"}`, string(c.Interactions[0].Response.Body))
+		assert.Equal(Redacted, c.Interactions[0].Response.Headers.Get("Set-Cookie"))
+		assert.Equal(Redacted, c.Interactions[1].Request.Headers.Get("Cookie"))
+		assert.Equal(srv.URL+"/echo?token=REDACTED", c.Interactions[1].Request.URL)
+		assert.Equal("hello REDACTED", string(c.Interactions[1].Response.Body))
+	}
+	assert.Contains(string(data), `"json": {`)
+	assert.Contains(string(data), `"text": "hello REDACTED"`)
+
+	// replay, without the server
+	srv.Close()
+	rep, err := NewReplayer(path)
+	assert.Nil(err)
+	client = &http.Client{Transport: rep}
+	resp, err = client.Post(srv.URL+"/user/signon?wc=true", "application/json", strings.NewReader(`{"password": "other", "username": "bob"}`))
+	assert.Nil(err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(200, resp.StatusCode)
+	assert.Contains(string(body), `"inboxId":"inbox42"`)
+	assert.Len(rep.Unused(), 1)
+
+	// unmatched requests
+	_, err = client.Post(srv.URL+"/user/signon?wc=true", "application/json", strings.NewReader(`{"password": "other", "username": "bob"}`))
+	assert.True(errors.Is(err, ErrUnmatched))
+	_, err = client.Post(srv.URL+"/echo?token=xyz", "text/plain", strings.NewReader("hello bob"))
+	assert.True(errors.Is(err, ErrUnmatched))
+
+	resp, err = client.Post(srv.URL+"/echo?token=xyz", "text/plain", strings.NewReader("hello REDACTED"))
+	assert.Nil(err)
+	assert.Equal(http.StatusTeapot, resp.StatusCode)
+	resp.Body.Close()
+	assert.Empty(rep.Unused())
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	// file not found
+	_, err := NewReplayer(filepath.Join(dir, "random.json"))
+	assert.NotNil(err)
+
+	// corrupted
+	path := filepath.Join(dir, "corrupted.json")
+	os.WriteFile(path, []byte("{"), 0644)
+	_, err = Load(path)
+	assert.ErrorContains(err, "corrupted")
+
+	// round trip, the indented json bodies of a hand-written cassette still match
+	c := &Cassette{Interactions: []Interaction{{
+		Request:  Request{Method: "GET", URL: "http://example.com/a"},
+		Response: Response{StatusCode: 200, Body: Body("[1, 2]")},
+	}}}
+	path = filepath.Join(dir, "cassette.json")
+	assert.Nil(c.Save(path))
+	loaded, err := Load(path)
+	assert.Nil(err)
+	assert.Nil(loaded.Interactions[0].Request.Body)
+	assert.Equal("[1,2]", string(loaded.Interactions[0].Response.Body))
+	assert.True(sameBody([]byte("{\n  \"b\": [1, 2],\n  \"a\": 1\n}"), []byte(`{"a":1,"b":[1,2]}`)))
+	assert.False(sameBody([]byte(`{"a":1}`), []byte(`{"a":2}`)))
+	assert.True(sameBody([]byte("text"), []byte("text")))
+}
@@ -0,0 +1,86 @@
+package ticktick
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	taskKindChecklist = "CHECKLIST"
+
+	checklistItemOpen      = 0
+	checklistItemCompleted = 1
+)
+
+// ChecklistItem is a subtask item of a CHECKLIST task, it is saved along with the task
+type ChecklistItem struct {
+	Id            string       `json:"id"`
+	Title         string       `json:"title"`
+	Status        int64        `json:"status"` // 0 open, 1 completed
+	SortOrder     int64        `json:"sortOrder"`
+	StartDate     TickTickTime `json:"startDate"`
+	IsAllDay      bool         `json:"isAllDay"`
+	CompletedTime TickTickTime `json:"completedTime"`
+}
+
+// Add an item at the end of the checklist, the task becomes a CHECKLIST task.
+// Call UpdateTask to save it.
+func (t *TaskItem) AddChecklistItem(title string) *ChecklistItem {
+	t.Kind = taskKindChecklist
+	sortOrder := int64(0)
+	for _, item := range t.Items {
+		if item.SortOrder >= sortOrder {
+			sortOrder = item.SortOrder + 1
+		}
+	}
+	t.Items = append(t.Items, ChecklistItem{
+		Id:        newObjectId(),
+		Title:     title,
+		Status:    checklistItemOpen,
+		SortOrder: sortOrder,
+	})
+	return &t.Items[len(t.Items)-1]
+}
+
+// Mark the item as completed, call UpdateTask to save it
+func (t *TaskItem) CompleteChecklistItem(id string) error {
+	for i := range t.Items {
+		if t.Items[i].Id == id {
+			t.Items[i].Status = checklistItemCompleted
+			t.Items[i].CompletedTime = NewTickTickTime(time.Now())
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrChecklistItemNotFound, id)
+}
+
+// Remove the item from the checklist, call UpdateTask to save it
+func (t *TaskItem) RemoveChecklistItem(id string) error {
+	for i := range t.Items {
+		if t.Items[i].Id == id {
+			// keep an empty, non nil slice, so that the removal of the last item is sent as []
+			items := make([]ChecklistItem, 0, len(t.Items)-1)
+			items = append(items, t.Items[:i]...)
+			t.Items = append(items, t.Items[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrChecklistItemNotFound, id)
+}
+
+// Turn the task into a CHECKLIST task like the app does: every non empty line of
+// the content becomes an item. Call UpdateTask to save it.
+func (t *TaskItem) ConvertToChecklist() {
+	if t.Kind == taskKindChecklist {
+		return
+	}
+	content := t.Content
+	t.Content = ""
+	t.Kind = taskKindChecklist
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			t.AddChecklistItem(line)
+		}
+	}
+}
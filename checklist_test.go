@@ -0,0 +1,97 @@
+package ticktick
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecklistSync(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+
+	// the items survive the sync and are sent back by UpdateTask
+	items := []ChecklistItem{
+		{Id: "i1", Title: "milk", Status: 1, SortOrder: 0, CompletedTime: MustParseTickTickTime("2023-01-01T10:00:00.000+0000")},
+		{Id: "i2", Title: "eggs", SortOrder: 1, StartDate: MustParseTickTickTime("2023-01-02T00:00:00.000+0000"), IsAllDay: true},
+	}
+	syncResponse := BuildSyncResponse()
+	syncResponse.SyncTaskBean.Update[0].Kind = "CHECKLIST"
+	syncResponse.SyncTaskBean.Update[0].Items = items
+	NewSignInTestServer()
+	NewSyncTestServer(syncResponse)
+	client, _ := NewClient("testuser", "testpass", "test")
+	tasks, _ := client.SearchTask("", "", "", "1", time.Time{}, time.Time{}, -1)
+	if !assert.Len(tasks, 1) {
+		return
+	}
+	assert.Equal(items, tasks[0].Items)
+
+	task := tasks[0]
+	task.Title = "groceries"
+	gock.New(baseUrlV2Test).
+		Post(fmt.Sprintf(taskUpdateUrlEndpoint, "1")).
+		MatchType("json").
+		JSON(task).
+		Reply(200).
+		JSON(task)
+	_, err := client.UpdateTask(&task)
+	assert.Nil(err)
+	assert.True(gock.IsDone())
+}
+
+func TestChecklistItems(t *testing.T) {
+	assert := assert.New(t)
+	task := TaskItem{Title: "groceries"}
+
+	// add
+	milk := task.AddChecklistItem("milk")
+	eggs := task.AddChecklistItem("eggs")
+	assert.Equal("CHECKLIST", task.Kind)
+	assert.Len(task.Items, 2)
+	assert.Equal("milk", milk.Title)
+	assert.Len(milk.Id, 24)
+	assert.Equal(int64(0), task.Items[0].SortOrder)
+	assert.Equal(int64(1), eggs.SortOrder)
+	eggsId := eggs.Id
+
+	// complete
+	assert.Nil(task.CompleteChecklistItem(eggsId))
+	assert.Equal(int64(1), task.Items[1].Status)
+	assert.WithinDuration(time.Now(), task.Items[1].CompletedTime.Time, time.Minute)
+	assert.True(errors.Is(task.CompleteChecklistItem("random"), ErrChecklistItemNotFound))
+
+	// remove
+	assert.Nil(task.RemoveChecklistItem(task.Items[0].Id))
+	assert.Equal(eggsId, task.Items[0].Id)
+	assert.Nil(task.RemoveChecklistItem(eggsId))
+	assert.NotNil(task.Items)
+	data, _ := json.Marshal(task)
+	assert.Contains(string(data), `"items":[]`)
+	assert.True(errors.Is(task.RemoveChecklistItem("random"), ErrChecklistItemNotFound))
+}
+
+func TestConvertToChecklist(t *testing.T) {
+	assert := assert.New(t)
+
+	task := TaskItem{Title: "groceries", Content: "milk\n\n  eggs \nbread", Kind: "TEXT"}
+	task.ConvertToChecklist()
+	assert.Equal("CHECKLIST", task.Kind)
+	assert.Empty(task.Content)
+	var titles []string
+	for _, item := range task.Items {
+		titles = append(titles, item.Title)
+	}
+	assert.Equal([]string{"milk", "eggs", "bread"}, titles)
+
+	// already a checklist
+	task.Content = "butter"
+	task.ConvertToChecklist()
+	assert.Len(task.Items, 3)
+	assert.Equal("butter", task.Content)
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/carlmjohnson/requests"
 	"github.com/tidwall/gjson"
@@ -13,60 +16,334 @@ const (
 	baseUrlV2Dida365              = "https://api.dida365.com/api/v2"
 	baseUrlV2Ticktick             = "https://api.ticktick.com/api/v2"
 	baseUrlV2Test                 = "https://api.test.com/api/v2"
-	signinUrlEndpoint             = "/user/signon?wc=true&remember=true" // POST
-	queryUnfinishedJobUrlEndpoint = "/batch/check/0"                     // GET
+	signinUrlEndpoint             = "/user/signon"    // POST
+	queryUnfinishedJobUrlEndpoint = "/batch/check/%v" // GET, with the checkpoint of the last sync
+
+	defaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:123.0) Gecko/20100101 Firefox/123.0"
 )
 
+// Client is safe for concurrent use, as long as UserName and PassWord are not
+// changed after it is created
 type Client struct {
 	UserName  string
 	PassWord  string
 	baseUrlV2 string
 
+	httpClient *http.Client
+	userAgent  string
+	tokenStore TokenStore
+
+	retryPolicy RetryPolicy
+	limiter     *rateLimiter
+
+	// syncMu serializes the syncs, authMu the sign ins after a rejected token,
+	// and mu guards the login token and the synced snapshot below
+	syncMu sync.Mutex
+	authMu sync.Mutex
+	mu     sync.RWMutex
+
 	loginToken string
+	userId     string // from the sign in, unknown with a stored token
 	inboxId    string
+	checkPoint int64
 
-	projectGroups []projectGroupsItem
+	projectGroups []ProjectGroup
 
+	projects       []Project
 	projectName2Id map[string]string
 	id2ProjectName map[string]string
 
 	tasks []TaskItem
 
-	tags []string
+	tags []Tag
+
+	filters []Filter
+}
+
+// Option configures a client created by NewClientWithOptions
+type Option func(*Client) error
+
+// set the login credentials
+func WithCredentials(userName, passWord string) Option {
+	return func(c *Client) error {
+		c.UserName = userName
+		c.PassWord = passWord
+		return nil
+	}
+}
+
+// use one of the built-in servers: ticktick, dida365, test
+func WithServer(server string) Option {
+	return func(c *Client) error {
+		baseUrlV2, err := serverBaseUrl(server)
+		if err != nil {
+			return err
+		}
+		c.baseUrlV2 = baseUrlV2
+		return nil
+	}
+}
+
+// use an arbitrary api base url, such as a self-hosted proxy or a local fake,
+// e.g. "http://127.0.0.1:8080/api/v2"
+func WithBaseURL(baseUrl string) Option {
+	return func(c *Client) error {
+		if baseUrl == "" {
+			return fmt.Errorf("base url is empty")
+		}
+		c.baseUrlV2 = baseUrl
+		return nil
+	}
+}
+
+// send the requests with the given http client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return fmt.Errorf("http client is nil")
+		}
+		hc := *httpClient
+		c.httpClient = &hc
+		return nil
+	}
+}
+
+// send the requests with the given transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) error {
+		if transport == nil {
+			return fmt.Errorf("transport is nil")
+		}
+		hc := c.cloneHTTPClient()
+		hc.Transport = transport
+		c.httpClient = hc
+		return nil
+	}
+}
+
+// limit the time of every request, including reading the response body
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout < 0 {
+			return fmt.Errorf("timeout %v is negative", timeout)
+		}
+		hc := c.cloneHTTPClient()
+		hc.Timeout = timeout
+		c.httpClient = hc
+		return nil
+	}
+}
+
+// set the User-Agent header of every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		c.userAgent = userAgent
+		return nil
+	}
+}
+
+// start with a login token instead of signing in
+func WithToken(token string) Option {
+	return func(c *Client) error {
+		c.loginToken = token
+		return nil
+	}
 }
 
-type projectGroupsItem struct {
-	id   string
-	name string
+// load the login token from the store, and save it there after every sign in
+func WithTokenStore(store TokenStore) Option {
+	return func(c *Client) error {
+		if store == nil {
+			return fmt.Errorf("token store is nil")
+		}
+		c.tokenStore = store
+		return nil
+	}
 }
 
 // create a new client, the server can be ticktick, dida365, test
 func NewClient(userName, passWord, server string) (*Client, error) {
-	var baseUrlV2 string
+	return NewClientWithOptions(WithCredentials(userName, passWord), WithServer(server))
+}
+
+// create a new client from options, the server defaults to ticktick
+func NewClientWithOptions(opts ...Option) (*Client, error) {
+	return NewClientWithOptionsCtx(context.Background(), opts...)
+}
+
+// create a new client from an existing login token, the server can be ticktick, dida365, test
+func NewClientFromToken(token, server string) (*Client, error) {
+	return NewClientWithOptions(WithToken(token), WithServer(server))
+}
+
+// NewClientWithOptionsCtx is NewClientWithOptions with a context for the initial login and sync.
+// A token given by WithToken or found in the TokenStore is used as is, and the client only
+// signs in when there is no token or the server rejects it.
+func NewClientWithOptionsCtx(ctx context.Context, opts ...Option) (*Client, error) {
+	client := &Client{
+		baseUrlV2:      baseUrlV2Ticktick,
+		userAgent:      defaultUserAgent,
+		projectName2Id: make(map[string]string),
+		id2ProjectName: make(map[string]string),
+	}
+	for _, opt := range opts {
+		if err := opt(client); err != nil {
+			return nil, err
+		}
+	}
+
+	if client.loginToken == "" && client.tokenStore != nil {
+		loginToken, err := client.tokenStore.Load(client.tokenKey())
+		if err != nil {
+			return nil, err
+		}
+		client.loginToken = loginToken
+	}
+	if client.loginToken != "" {
+		// the sync signs in again by itself if the token is rejected
+		if err := client.SyncCtx(ctx); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	if err := client.InitCtx(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func serverBaseUrl(server string) (string, error) {
 	switch server {
 	case "ticktick":
-		baseUrlV2 = baseUrlV2Ticktick
+		return baseUrlV2Ticktick, nil
 	case "dida365":
-		baseUrlV2 = baseUrlV2Dida365
+		return baseUrlV2Dida365, nil
 	case "test":
-		baseUrlV2 = baseUrlV2Test
+		return baseUrlV2Test, nil
 	default:
-		return nil, fmt.Errorf("server name %v is not supported", server)
+		return "", fmt.Errorf("server name %v is not supported", server)
 	}
+}
 
-	client := &Client{UserName: userName, PassWord: passWord, baseUrlV2: baseUrlV2, projectName2Id: make(map[string]string), id2ProjectName: make(map[string]string)}
-	if err := client.Init(); err != nil {
-		return nil, err
+// the key of the login token in the TokenStore
+func (c *Client) tokenKey() string {
+	return c.baseUrlV2 + "#" + c.UserName
+}
+
+func (c *Client) cloneHTTPClient() *http.Client {
+	if c.httpClient == nil {
+		return &http.Client{}
 	}
-	return client, nil
+	hc := *c.httpClient
+	return &hc
+}
+
+// build a request to the api endpoint, carrying the login token if we have one
+func (c *Client) request(endpoint string) *requests.Builder {
+	rb := requests.URL(c.baseUrlV2 + endpoint).AddValidator(checkAPIError)
+	if c.httpClient != nil {
+		rb.Client(c.httpClient)
+	}
+	if c.userAgent != "" {
+		rb.UserAgent(c.userAgent)
+	}
+	if loginToken := c.token(); loginToken != "" {
+		rb.Cookie("t", loginToken)
+	}
+	return rb
+}
+
+func (c *Client) token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loginToken
+}
+
+func (c *Client) projectId(projectName string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, ok := c.projectName2Id[projectName]
+	return id, ok
+}
+
+func (c *Client) projectName(projectId string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.id2ProjectName[projectId]
+}
+
+// the names of the synced projects, the inbox included
+func (c *Client) projectNames() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.projectName2Id))
+	for name := range c.projectName2Id {
+		names = append(names, name)
+	}
+	return names
+}
+
+// send the request after waiting for the rate limiter
+func (c *Client) send(ctx context.Context, rb *requests.Builder) error {
+	if c.limiter != nil {
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return rb.Fetch(ctx)
+}
+
+// fetch for the idempotent requests, which are also retried with the retry policy
+func (c *Client) fetchIdempotent(ctx context.Context, build func() *requests.Builder) error {
+	return c.retry(ctx, func() error {
+		return c.fetch(ctx, build)
+	})
+}
+
+// send the request built by build. If the server rejects the login token, sign in again
+// and retry once, build is called again so that the new token is used.
+func (c *Client) fetch(ctx context.Context, build func() *requests.Builder) error {
+	loginToken := c.token()
+	err := c.send(ctx, build())
+	if !isUnauthorized(err) {
+		return err
+	}
+	if c.PassWord == "" {
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	if err := c.refreshToken(ctx, loginToken); err != nil {
+		return fmt.Errorf("%w: failed to sign in again: %w", ErrUnauthorized, err)
+	}
+
+	err = c.send(ctx, build())
+	if isUnauthorized(err) {
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	return err
+}
+
+// sign in again unless another request has already replaced the rejected token
+func (c *Client) refreshToken(ctx context.Context, rejected string) error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if c.token() != rejected {
+		return nil
+	}
+	return c.GetTokenCtx(ctx)
 }
 
 // init the client struct (login, sync)
 func (c *Client) Init() error {
-	if err := c.GetToken(); err != nil {
+	return c.InitCtx(context.Background())
+}
+
+// InitCtx is Init with a context
+func (c *Client) InitCtx(ctx context.Context) error {
+	if err := c.GetTokenCtx(ctx); err != nil {
 		return err
 	}
-	if err := c.Sync(); err != nil {
+	if err := c.SyncCtx(ctx); err != nil {
 		return err
 	}
 	return nil
@@ -74,18 +351,24 @@ func (c *Client) Init() error {
 
 // get the ticktick token
 func (c *Client) GetToken() error {
+	return c.GetTokenCtx(context.Background())
+}
+
+// GetTokenCtx is GetToken with a context
+func (c *Client) GetTokenCtx(ctx context.Context) error {
 	body := map[string]string{
 		"username": c.UserName,
 		"password": c.PassWord,
 	}
 	var resp string
 
-	if err := requests.
-		URL(c.baseUrlV2+signinUrlEndpoint).
-		BodyJSON(&body).
-		ToString(&resp).
-		Header("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:123.0) Gecko/20100101 Firefox/123.0").
-		Fetch(context.Background()); err != nil {
+	if err := c.retrySignIn(ctx, func() error {
+		return c.send(ctx, c.request(signinUrlEndpoint).
+			Param("wc", "true").
+			Param("remember", "true").
+			BodyJSON(&body).
+			ToString(&resp))
+	}); err != nil {
 		return err
 	}
 
@@ -93,58 +376,170 @@ func (c *Client) GetToken() error {
 	if loginToken == "" {
 		return fmt.Errorf("no token found in the response, full response json is %v", resp)
 	}
+	c.mu.Lock()
 	c.loginToken = loginToken
+	c.userId = gjson.Get(resp, "userId").String()
+	c.mu.Unlock()
+
+	if c.tokenStore != nil {
+		if err := c.tokenStore.Save(c.tokenKey(), loginToken); err != nil {
+			return fmt.Errorf("failed to save the login token: %w", err)
+		}
+	}
 	return nil
 }
 
-// fetch all the user contents
+// fetch the user contents changed since the last sync, the first sync fetches everything.
+// The tasks that can not be decoded are left out of the synced tasks.
 func (c *Client) Sync() error {
-	var resp string
+	return c.SyncCtx(context.Background())
+}
+
+// SyncCtx is Sync with a context
+func (c *Client) SyncCtx(ctx context.Context) error {
+	return c.sync(ctx, false)
+}
+
+// drop the local state and fetch all the user contents again
+func (c *Client) FullSync() error {
+	return c.FullSyncCtx(context.Background())
+}
 
-	if err := requests.
-		URL(c.baseUrlV2+queryUnfinishedJobUrlEndpoint).
-		Cookie("t", c.loginToken).
-		ToString(&resp).
-		Fetch(context.Background()); err != nil {
+// FullSyncCtx is FullSync with a context
+func (c *Client) FullSyncCtx(ctx context.Context) error {
+	return c.sync(ctx, true)
+}
+
+func (c *Client) sync(ctx context.Context, full bool) error {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	c.mu.RLock()
+	checkPoint := c.checkPoint
+	c.mu.RUnlock()
+	if full {
+		checkPoint = 0
+	}
+
+	var resp string
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, checkPoint)).
+			ToString(&resp)
+	}); err != nil {
 		return err
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applySync(resp, checkPoint == 0)
+	return nil
+}
+
+// apply a /batch/check response to the local state, c.mu must be held. Projects, project groups, tags
+// and filters are replaced whenever the response carries them, while tasks are applied as a delta
+// unless full is set.
+func (c *Client) applySync(resp string, full bool) {
 	// below we assume the apis are stable
-	c.inboxId = gjson.Get(resp, "inboxId").String()
+	if inboxId := gjson.Get(resp, "inboxId").String(); inboxId != "" || full {
+		c.inboxId = inboxId
+	}
 
-	c.projectGroups = nil
-	gjson.Get(resp, "projectGroups").ForEach(func(key, value gjson.Result) bool {
-		c.projectGroups = append(c.projectGroups, projectGroupsItem{
-			id:   value.Get("id").String(),
-			name: value.Get("name").String(),
+	if projectGroups := gjson.Get(resp, "projectGroups"); projectGroups.IsArray() || full {
+		c.projectGroups = nil
+		projectGroups.ForEach(func(key, value gjson.Result) bool {
+			var g ProjectGroup
+			json.Unmarshal([]byte(value.Raw), &g)
+			c.projectGroups = append(c.projectGroups, g)
+			return true
 		})
-		return true
-	})
+	}
 
-	c.projectName2Id = make(map[string]string)
+	if projectProfiles := gjson.Get(resp, "projectProfiles"); projectProfiles.IsArray() || full {
+		c.projects = nil
+		projectProfiles.ForEach(func(key, value gjson.Result) bool {
+			var p Project
+			json.Unmarshal([]byte(value.Raw), &p)
+			c.projects = append(c.projects, p)
+			return true
+		})
+		c.indexProjects()
+	}
 	c.projectName2Id["inbox"] = c.inboxId
-	c.id2ProjectName = make(map[string]string)
 	c.id2ProjectName[c.inboxId] = "inbox"
-	gjson.Get(resp, "projectProfiles").ForEach(func(key, value gjson.Result) bool {
-		c.projectName2Id[value.Get("name").String()] = value.Get("id").String()
-		c.id2ProjectName[value.Get("id").String()] = value.Get("name").String()
+
+	if full {
+		c.tasks = nil
+	}
+	index := make(map[string]int, len(c.tasks))
+	for i, t := range c.tasks {
+		index[t.Id] = i
+	}
+	deleted := make(map[string]bool)
+	gjson.Get(resp, "syncTaskBean.delete").ForEach(func(key, value gjson.Result) bool {
+		deleted[value.Get("taskId").String()] = true
 		return true
 	})
-
-	c.tasks = nil
-	gjson.Get(resp, "syncTaskBean.update").ForEach(func(key, value gjson.Result) bool {
+	var dropped []string
+	upsert := func(key, value gjson.Result) bool {
 		var t TaskItem
-		json.Unmarshal([]byte(value.Raw), &t)
-		t.ProjectName = c.id2ProjectName[t.ProjectId]
-		c.tasks = append(c.tasks, t)
+		if err := json.Unmarshal([]byte(value.Raw), &t); err != nil || t.Id == "" {
+			// a task that can not be decoded is left out, its older version included
+			dropped = append(dropped, value.Get("id").String())
+			return true
+		}
+		if t.Status != Open {
+			// completed or abandoned since the last sync, only open tasks are kept
+			dropped = append(dropped, t.Id)
+			return true
+		}
+		if i, ok := index[t.Id]; ok {
+			c.tasks[i] = t
+		} else {
+			index[t.Id] = len(c.tasks)
+			c.tasks = append(c.tasks, t)
+		}
 		return true
-	})
+	}
+	gjson.Get(resp, "syncTaskBean.add").ForEach(upsert)
+	gjson.Get(resp, "syncTaskBean.update").ForEach(upsert)
+	for _, id := range dropped {
+		if _, ok := index[id]; ok && id != "" {
+			deleted[id] = true
+		}
+	}
+	if len(deleted) > 0 {
+		tasks := c.tasks[:0]
+		for _, t := range c.tasks {
+			if !deleted[t.Id] {
+				tasks = append(tasks, t)
+			}
+		}
+		c.tasks = tasks
+	}
+	// project names may have changed even for the tasks untouched by this delta
+	for i := range c.tasks {
+		c.tasks[i].ProjectName = c.id2ProjectName[c.tasks[i].ProjectId]
+	}
 
-	c.tags = nil
-	gjson.Get(resp, "tags").ForEach(func(key, value gjson.Result) bool {
-		c.tags = append(c.tags, value.Get("name").String())
-		return true
-	})
+	if tags := gjson.Get(resp, "tags"); tags.IsArray() || full {
+		c.tags = nil
+		tags.ForEach(func(key, value gjson.Result) bool {
+			var t Tag
+			json.Unmarshal([]byte(value.Raw), &t)
+			c.tags = append(c.tags, t)
+			return true
+		})
+	}
 
-	return nil
+	if filters := gjson.Get(resp, "filters"); filters.IsArray() || full {
+		c.filters = nil
+		filters.ForEach(func(key, value gjson.Result) bool {
+			var f Filter
+			json.Unmarshal([]byte(value.Raw), &f)
+			c.filters = append(c.filters, f)
+			return true
+		})
+	}
+
+	c.checkPoint = gjson.Get(resp, "checkPoint").Int()
 }
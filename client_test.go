@@ -1,8 +1,16 @@
 package ticktick
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
@@ -19,11 +27,19 @@ type TestTagItem struct {
 	Name string `json:"name"`
 }
 
+type testsyncDeleteItem struct {
+	TaskId    string `json:"taskId"`
+	ProjectId string `json:"projectId"`
+}
+
 type testsyncTask struct {
-	Update []TaskItem `json:"update"`
+	Add    []TaskItem           `json:"add,omitempty"`
+	Update []TaskItem           `json:"update"`
+	Delete []testsyncDeleteItem `json:"delete,omitempty"`
 }
 
 type TestSyncResponse struct {
+	CheckPoint      int64             `json:"checkPoint,omitempty"`
 	InboxId         string            `json:"inboxId"`
 	ProjectGroups   []TestProjectItem `json:"projectGroups"`
 	ProjectProfiles []TestProjectItem `json:"projectProfiles"`
@@ -61,7 +77,7 @@ func BuildSyncResponse() *TestSyncResponse {
 					Title:     "1",
 					ProjectId: "pid1",
 					Tags:      []string{"a", "b"},
-					StartDate: "2022-12-12T15:04:05.000+0000",
+					StartDate: MustParseTickTickTime("2022-12-12T15:04:05.000+0000"),
 					Priority:  5,
 				},
 				{
@@ -69,7 +85,7 @@ func BuildSyncResponse() *TestSyncResponse {
 					Title:     "2",
 					ProjectId: "pid1",
 					Tags:      []string{"b", "c"},
-					StartDate: "2022-12-13T15:04:05.000+0000",
+					StartDate: MustParseTickTickTime("2022-12-13T15:04:05.000+0000"),
 					Priority:  0,
 				},
 				{
@@ -77,7 +93,7 @@ func BuildSyncResponse() *TestSyncResponse {
 					Title:     "3",
 					ProjectId: "pid2",
 					Tags:      []string{"b", "c"},
-					StartDate: "2022-12-14T15:04:05.000+0000",
+					StartDate: MustParseTickTickTime("2022-12-14T15:04:05.000+0000"),
 					Priority:  1,
 				},
 			},
@@ -106,12 +122,12 @@ func NewSignInTestServer() {
 			"password": "testpass",
 		}).
 		Reply(200).
-		JSON(map[string]string{"token": "testtoken"})
+		JSON(map[string]string{"token": "testtoken", "userId": "testuserid"})
 }
 
 func NewSyncTestServer(syncResponse *TestSyncResponse) {
 	gock.New(baseUrlV2Test).
-		Get(queryUnfinishedJobUrlEndpoint).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
 		MatchHeader("Cookie", "t=testtoken").
 		Reply(200).
 		JSON(syncResponse)
@@ -185,12 +201,131 @@ func TestSync(t *testing.T) {
 
 	// if the server responses 404
 	gock.New(baseUrlV2Test).
-		Get(queryUnfinishedJobUrlEndpoint).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
 		Reply(404)
 	err = client.Sync()
 	assert.NotNil(t, err)
 }
 
+func TestSyncDelta(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+
+	client := &Client{
+		UserName:       "testuser",
+		PassWord:       "testpass",
+		baseUrlV2:      baseUrlV2Test,
+		projectName2Id: make(map[string]string),
+		id2ProjectName: make(map[string]string),
+		loginToken:     "testtoken",
+	}
+	taskTitles := func() []string {
+		var titles []string
+		for _, task := range client.tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+
+	// the first sync fetches everything and remembers the checkpoint
+	syncResponse := BuildSyncResponse()
+	syncResponse.CheckPoint = 100
+	NewSyncTestServer(syncResponse)
+	assert.Nil(client.Sync())
+	assert.Equal(int64(100), client.checkPoint)
+	assert.Equal([]string{"1", "2", "3"}, taskTitles())
+
+	// a delta updating, adding and deleting tasks, without projects and tags
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 100)).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		JSON(map[string]any{
+			"checkPoint": 200,
+			"syncTaskBean": testsyncTask{
+				Add:    []TaskItem{{Id: "4", Title: "4", ProjectId: "pid2"}},
+				Update: []TaskItem{{Id: "2", Title: "2 updated", ProjectId: "pid2"}},
+				Delete: []testsyncDeleteItem{{TaskId: "1", ProjectId: "pid1"}},
+			},
+		})
+	assert.Nil(client.Sync())
+	assert.Equal(int64(200), client.checkPoint)
+	assert.Equal([]string{"2 updated", "3", "4"}, taskTitles())
+	assert.Equal("pname2", client.tasks[0].ProjectName)
+	assert.Equal("pname2", client.tasks[2].ProjectName)
+	assert.Equal([]string{"a", "b", "c"}, tagNames(client.tags))
+	assert.Equal("pid1", client.projectName2Id["pname1"])
+	assert.Equal("testinboxid", client.projectName2Id["inbox"])
+
+	// a delta renaming a project and dropping a tag
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 200)).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		JSON(map[string]any{
+			"checkPoint":      300,
+			"projectProfiles": []TestProjectItem{{Name: "pname1", Id: "pid1"}, {Name: "pname2 renamed", Id: "pid2"}},
+			"syncTaskBean":    map[string]any{"empty": true},
+			"tags":            []TestTagItem{{Name: "a"}},
+		})
+	assert.Nil(client.Sync())
+	assert.Equal(int64(300), client.checkPoint)
+	assert.Equal([]string{"2 updated", "3", "4"}, taskTitles())
+	assert.Equal("pname2 renamed", client.tasks[1].ProjectName)
+	assert.Equal([]string{"a"}, tagNames(client.tags))
+	assert.Equal("testinboxid", client.projectName2Id["inbox"])
+
+	// the odd values are decoded, the tasks that can not be decoded are left out
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 300)).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		BodyString(`{"checkPoint": 400, "syncTaskBean": {
+			"add": [{"id": "5", "title": "5", "dueDate": "2023-01-02", "repeatFrom": 1, "reminders": [{"id": "r1", "trigger": "TRIGGER:-PT15M30.5S"}]},
+				{"id": "6", "title": "6", "dueDate": "yesterday"}],
+			"update": [{"id": "3", "title": "3 updated", "priority": "high"}, {"title": "no id"}]}}`)
+	assert.Nil(client.Sync())
+	assert.Equal(int64(400), client.checkPoint)
+	assert.Equal([]string{"2 updated", "4", "5"}, taskTitles())
+	added := client.tasks[2]
+	assert.Equal("2023-01-02T00:00:00.000+0000", added.DueDate.String())
+	assert.Equal(RepeatFromCompletedTime, added.RepeatFrom)
+	assert.Equal("TRIGGER:-PT15M30.5S", added.Reminders[0].String())
+
+	// the tasks completed or abandoned since the last sync come as updates and are dropped
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 400)).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		JSON(map[string]any{
+			"checkPoint": 500,
+			"syncTaskBean": testsyncTask{
+				Add: []TaskItem{{Id: "7", Title: "7", Status: Abandoned}},
+				Update: []TaskItem{{Id: "4", Title: "4", ProjectId: "pid2", Status: Completed,
+					CompletedTime: MustParseTickTickTime("2023-01-03T00:00:00.000+0000")}},
+			},
+		})
+	assert.Nil(client.Sync())
+	assert.Equal(int64(500), client.checkPoint)
+	assert.Equal([]string{"2 updated", "5"}, taskTitles())
+
+	// a failed delta keeps the local state and the checkpoint
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 500)).
+		Reply(500)
+	assert.NotNil(client.Sync())
+	assert.Equal(int64(500), client.checkPoint)
+	assert.Equal([]string{"2 updated", "5"}, taskTitles())
+
+	// a full sync starts again from the checkpoint 0
+	NewSyncTestServer(BuildSyncResponse())
+	assert.Nil(client.FullSync())
+	assert.Equal(int64(0), client.checkPoint)
+	assert.Equal([]string{"1", "2", "3"}, taskTitles())
+	assert.Equal([]string{"a", "b", "c"}, tagNames(client.tags))
+	assert.True(gock.IsDone())
+}
+
 func TestInit(t *testing.T) {
 	defer gock.Off()
 	assert := assert.New(t)
@@ -220,7 +355,7 @@ func TestInit(t *testing.T) {
 	// if sync has problem
 	NewSignInTestServer()
 	gock.New(baseUrlV2Test).
-		Get(queryUnfinishedJobUrlEndpoint).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
 		Reply(404)
 
 	err = client.Init()
@@ -254,3 +389,329 @@ func TestNewClient(t *testing.T) {
 	assert.Nil(client)
 
 }
+
+func NewOptionsTestServer(t *testing.T, userAgent string) *httptest.Server {
+	syncResponse := BuildSyncResponse()
+	syncResponse.SyncTaskBean.Update[0].Items = []ChecklistItem{{Id: "i1", Title: "item"}}
+	syncResponse.SyncTaskBean.Update[0].Reminders = []Reminder{{Id: "r1", Trigger: -30 * time.Minute}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/user/signon", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, userAgent, r.UserAgent())
+		assert.Equal(t, "true", r.URL.Query().Get("wc"))
+		json.NewEncoder(w).Encode(map[string]string{"token": "testtoken"})
+	})
+	mux.HandleFunc("/api/v2/batch/check/0", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, userAgent, r.UserAgent())
+		cookie, err := r.Cookie("t")
+		if assert.Nil(t, err) {
+			assert.Equal(t, "testtoken", cookie.Value)
+		}
+		json.NewEncoder(w).Encode(syncResponse)
+	})
+	return httptest.NewServer(mux)
+}
+
+type countingTransport struct {
+	count int
+	inner http.RoundTripper
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.count++
+	return ct.inner.RoundTrip(req)
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	// custom base url, http client and user agent
+	server := NewOptionsTestServer(t, "go-ticktick-test")
+	defer server.Close()
+	client, err := NewClientWithOptions(
+		WithCredentials("testuser", "testpass"),
+		WithBaseURL(server.URL+"/api/v2"),
+		WithHTTPClient(server.Client()),
+		WithUserAgent("go-ticktick-test"),
+		WithTimeout(time.Second),
+	)
+	if assert.Nil(err) {
+		assert.Equal("testtoken", client.loginToken)
+		assert.Equal("pid1", client.projectName2Id["pname1"])
+		assert.Equal(time.Second, client.httpClient.Timeout)
+	}
+
+	// custom transport
+	transport := &countingTransport{inner: server.Client().Transport}
+	_, err = NewClientWithOptions(
+		WithCredentials("testuser", "testpass"),
+		WithBaseURL(server.URL+"/api/v2"),
+		WithTransport(transport),
+		WithUserAgent("go-ticktick-test"),
+	)
+	assert.Nil(err)
+	assert.Equal(2, transport.count)
+
+	// the default user agent
+	defaultServer := NewOptionsTestServer(t, defaultUserAgent)
+	defer defaultServer.Close()
+	_, err = NewClientWithOptions(
+		WithCredentials("testuser", "testpass"),
+		WithBaseURL(defaultServer.URL+"/api/v2"),
+		WithHTTPClient(defaultServer.Client()),
+	)
+	assert.Nil(err)
+
+	// invalid options
+	_, err = NewClientWithOptions(WithServer("random"))
+	assert.NotNil(err)
+	_, err = NewClientWithOptions(WithBaseURL(""))
+	assert.NotNil(err)
+	_, err = NewClientWithOptions(WithHTTPClient(nil))
+	assert.NotNil(err)
+	_, err = NewClientWithOptions(WithTransport(nil))
+	assert.NotNil(err)
+	_, err = NewClientWithOptions(WithTimeout(-time.Second))
+	assert.NotNil(err)
+}
+
+// a server that never answers until the request is aborted
+func NewBlockingTestServer() (*httptest.Server, chan struct{}) {
+	arrived := make(chan struct{}, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// drain the body so that the server notices when the client goes away
+		io.Copy(io.Discard, r.Body)
+		arrived <- struct{}{}
+		<-r.Context().Done()
+	}))
+	return server, arrived
+}
+
+func BuildBlockingClient(server *httptest.Server) *Client {
+	return &Client{
+		UserName:       "testuser",
+		PassWord:       "testpass",
+		baseUrlV2:      server.URL + "/api/v2",
+		httpClient:     server.Client(),
+		projectName2Id: map[string]string{"pname1": "pid1", "pname2": "pid2"},
+		id2ProjectName: map[string]string{"pid1": "pname1", "pid2": "pname2"},
+		loginToken:     "testtoken",
+	}
+}
+
+func TestSyncCtx(t *testing.T) {
+	assert := assert.New(t)
+	server, arrived := NewBlockingTestServer()
+	defer server.Close()
+	client := BuildBlockingClient(server)
+
+	// cancel an in-flight sync
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+	err := client.SyncCtx(ctx)
+	if assert.NotNil(err) {
+		assert.True(errors.Is(err, context.Canceled))
+	}
+
+	// deadline exceeded
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.SyncCtx(ctx)
+	if assert.NotNil(err) {
+		assert.True(errors.Is(err, context.DeadlineExceeded))
+	}
+
+	// login and init also honor the context
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.GetTokenCtx(ctx)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	err = client.InitCtx(ctx)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func NewConcurrentTestServer() *httptest.Server {
+	var mu sync.Mutex
+	checkPoint := int64(0)
+	created := 0
+	syncResponse := BuildSyncResponse()
+	syncResponse.SyncTaskBean.Update[0].Items = []ChecklistItem{{Id: "i1", Title: "item"}}
+	syncResponse.SyncTaskBean.Update[0].Reminders = []Reminder{{Id: "r1", Trigger: -30 * time.Minute}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/user/signon", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"token": "testtoken"})
+	})
+	mux.HandleFunc("/api/v2/batch/check/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		checkPoint++
+		resp := *syncResponse
+		resp.CheckPoint = checkPoint
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/api/v2/task", func(w http.ResponseWriter, r *http.Request) {
+		var task TaskItem
+		json.NewDecoder(r.Body).Decode(&task)
+		mu.Lock()
+		created++
+		task.Id = fmt.Sprint("created", created)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(task)
+	})
+	mux.HandleFunc("/api/v2/batch/taskProject", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"id2etag": map[string]string{}, "id2error": map[string]string{}})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	assert := assert.New(t)
+	server := NewConcurrentTestServer()
+	defer server.Close()
+	client, err := NewClientWithOptions(
+		WithCredentials("testuser", "testpass"),
+		WithBaseURL(server.URL+"/api/v2"),
+		WithHTTPClient(server.Client()),
+	)
+	if !assert.Nil(err) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(5)
+		go func() {
+			defer wg.Done()
+			tasks, err := client.SearchTask("", "pname1", "", "", time.Time{}, time.Time{}, -1)
+			assert.Nil(err)
+			assert.Len(tasks, 2)
+		}()
+		go func() {
+			defer wg.Done()
+			task, err := NewTask(client, "test", "", time.Time{}, "pname1")
+			if assert.Nil(err) {
+				task, err = client.CreateTask(task)
+				assert.Nil(err)
+				_, err = client.MoveTask(task, "pname2")
+				assert.Nil(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Nil(client.Sync())
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Nil(client.FullSync())
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Nil(client.GetToken())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentEdits(t *testing.T) {
+	assert := assert.New(t)
+	server := NewConcurrentTestServer()
+	defer server.Close()
+	client, err := NewClientWithOptions(
+		WithCredentials("testuser", "testpass"),
+		WithBaseURL(server.URL+"/api/v2"),
+		WithHTTPClient(server.Client()),
+	)
+	if !assert.Nil(err) {
+		return
+	}
+	edit := func(tasks []TaskItem) {
+		for i := range tasks {
+			tasks[i].Tags[0] = "edited"
+			if len(tasks[i].Items) > 0 {
+				assert.Nil(tasks[i].CompleteChecklistItem("i1"))
+				tasks[i].Reminders[0].Trigger = 0
+			}
+		}
+	}
+
+	// the results do not share their slices with the snapshot
+	tasks, err := client.QueryTasks(Query().Priority(5))
+	if assert.Nil(err) && assert.Len(tasks, 1) {
+		edit(tasks)
+	}
+	tasks, _ = client.SearchTask("", "", "", "", time.Time{}, time.Time{}, -1)
+	edit(tasks)
+	client.mu.RLock()
+	assert.Equal([]string{"a", "b"}, client.tasks[0].Tags)
+	assert.Equal(int64(checklistItemOpen), client.tasks[0].Items[0].Status)
+	assert.Equal(-30*time.Minute, client.tasks[0].Reminders[0].Trigger)
+	client.mu.RUnlock()
+
+	// editing them while the client syncs and queries is not a race
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tasks, err := client.QueryTasks(Query())
+			if assert.Nil(err) {
+				edit(tasks)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Nil(client.Sync())
+			tasks, err := client.QueryTasks(Query().WithAnyTag("edited"))
+			assert.Nil(err)
+			assert.Empty(tasks)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFetchReauth(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+
+	client := &Client{
+		UserName:       "testuser",
+		PassWord:       "testpass",
+		baseUrlV2:      baseUrlV2Test,
+		projectName2Id: make(map[string]string),
+		id2ProjectName: make(map[string]string),
+		loginToken:     "expiredtoken",
+	}
+
+	// the sign in fails as well
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(401)
+	gock.New(baseUrlV2Test).
+		Post(signinUrlEndpoint).
+		Reply(500)
+	err := client.Sync()
+	if assert.NotNil(err) {
+		assert.True(errors.Is(err, ErrUnauthorized))
+		assert.Contains(fmt.Sprint(err), "failed to sign in again")
+	}
+
+	// no password to sign in again
+	client.PassWord = ""
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(401)
+	err = client.Sync()
+	assert.True(errors.Is(err, ErrUnauthorized))
+
+	// other errors are not retried
+	client.PassWord = "testpass"
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(404)
+	err = client.Sync()
+	assert.NotNil(err)
+	assert.False(errors.Is(err, ErrUnauthorized))
+	assert.True(gock.IsDone())
+}
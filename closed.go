@@ -0,0 +1,123 @@
+package ticktick
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+)
+
+const (
+	closedTaskUrlEndpoint = "/project/%v/closed" // GET, with the project id or all
+
+	closedTaskLimit      = 50 // the tasks per page
+	closedTaskTimeLayout = "2006-01-02 15:04:05"
+)
+
+// The tasks completed in [from, to], the most recently completed first. Without project ids
+// the tasks of all the projects are listed. A zero from or to leaves the range open.
+// The api pages by completed time only, so when more than 50 tasks are completed in the same
+// second the rest of them can not be listed: the other tasks are still returned, together
+// with ErrClosedTasksTruncated.
+func (c *Client) ListCompletedTasks(from, to time.Time, projectIds ...string) ([]TaskItem, error) {
+	return c.ListCompletedTasksCtx(context.Background(), from, to, projectIds...)
+}
+
+// ListCompletedTasksCtx is ListCompletedTasks with a context
+func (c *Client) ListCompletedTasksCtx(ctx context.Context, from, to time.Time, projectIds ...string) ([]TaskItem, error) {
+	return c.listClosedTasks(ctx, "Completed", from, to, projectIds)
+}
+
+// The tasks marked as won't do in [from, to], like ListCompletedTasks
+func (c *Client) ListAbandonedTasks(from, to time.Time, projectIds ...string) ([]TaskItem, error) {
+	return c.ListAbandonedTasksCtx(context.Background(), from, to, projectIds...)
+}
+
+// ListAbandonedTasksCtx is ListAbandonedTasks with a context
+func (c *Client) ListAbandonedTasksCtx(ctx context.Context, from, to time.Time, projectIds ...string) ([]TaskItem, error) {
+	return c.listClosedTasks(ctx, "Abandoned", from, to, projectIds)
+}
+
+func (c *Client) listClosedTasks(ctx context.Context, status string, from, to time.Time, projectIds []string) ([]TaskItem, error) {
+	if len(projectIds) == 0 {
+		projectIds = []string{"all"}
+	}
+	var res []TaskItem
+	var truncated []string
+	seen := make(map[string]bool)
+	for _, projectId := range projectIds {
+		// the pages go back in time, each one ends at the last completed time of the previous one
+		end := to
+		for {
+			var page []TaskItem
+			if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+				return c.request(fmt.Sprintf(closedTaskUrlEndpoint, projectId)).
+					Param("from", formatClosedTaskTime(from)).
+					Param("to", formatClosedTaskTime(end)).
+					Param("status", status).
+					Param("limit", strconv.Itoa(closedTaskLimit)).
+					ToJSON(&page)
+			}); err != nil {
+				return nil, err
+			}
+
+			added := 0
+			for _, t := range page {
+				if seen[t.Id] {
+					continue
+				}
+				seen[t.Id] = true
+				t.ProjectName = c.projectName(t.ProjectId)
+				res = append(res, t)
+				added++
+				// a task without completed time can not end a page
+				if !t.CompletedTime.IsZero() && (end.IsZero() || t.CompletedTime.Before(end)) {
+					end = t.CompletedTime.Time
+				}
+			}
+			// the tasks completed in the same second as the page end are on the next page too
+			if len(page) < closedTaskLimit {
+				break
+			}
+			if added == 0 {
+				// the whole page is in the second of its end, skip the rest of that second
+				if end.IsZero() {
+					truncated = append(truncated, projectId)
+					break
+				}
+				truncated = append(truncated, fmt.Sprintf("%v at %v", projectId, formatClosedTaskTime(end)))
+				end = end.Add(-time.Second)
+			}
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CompletedTime.After(res[j].CompletedTime.Time) })
+	if len(truncated) > 0 {
+		return res, fmt.Errorf("%w: %v", ErrClosedTasksTruncated, strings.Join(truncated, ", "))
+	}
+	return res, nil
+}
+
+func formatClosedTaskTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(closedTaskTimeLayout)
+}
+
+// Reopen a completed or abandoned task, return the updated task
+func (c *Client) ReopenTask(t *TaskItem) (*TaskItem, error) {
+	return c.ReopenTaskCtx(context.Background(), t)
+}
+
+// ReopenTaskCtx is ReopenTask with a context
+func (c *Client) ReopenTaskCtx(ctx context.Context, t *TaskItem) (*TaskItem, error) {
+	newt := *t
+	newt.Status = Open
+	newt.CompletedTime = TickTickTime{}
+	return c.UpdateTaskCtx(ctx, &newt)
+}
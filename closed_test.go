@@ -0,0 +1,147 @@
+package ticktick
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListCompletedTasks(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+	gock.Off()
+
+	// 60 tasks of pid1 completed a minute apart, and one of pid2, in the order of completion
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	var closed []TaskItem
+	for i := 0; i < 60; i++ {
+		closed = append(closed, TaskItem{Id: fmt.Sprint("c", i), ProjectId: "pid1", Status: Completed, CompletedTime: NewTickTickTime(start.Add(time.Duration(i) * time.Minute))})
+		if i == 0 {
+			closed = append(closed, TaskItem{Id: "other", ProjectId: "pid2", Status: Completed, CompletedTime: NewTickTickTime(start.Add(30 * time.Second))})
+		}
+	}
+
+	var queries []string
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		q := r.URL.Query()
+		from, _ := time.Parse(closedTaskTimeLayout, q.Get("from"))
+		to, _ := time.Parse(closedTaskTimeLayout, q.Get("to"))
+		var page []TaskItem
+		for i := len(closed) - 1; i >= 0 && len(page) < closedTaskLimit; i-- {
+			task := closed[i]
+			if r.URL.Path != "/api/v2/project/all/closed" && r.URL.Path != fmt.Sprintf("/api/v2/project/%v/closed", task.ProjectId) {
+				continue
+			}
+			if q.Get("status") != "Completed" || task.CompletedTime.Before(from) || (!to.IsZero() && task.CompletedTime.After(to)) {
+				continue
+			}
+			page = append(page, task)
+		}
+		data, _ := json.Marshal(page)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(data)), Header: http.Header{}}, nil
+	})}
+
+	// normal case, on 2 pages
+	tasks, err := client.ListCompletedTasks(start, start.Add(2*time.Hour), "pid1")
+	assert.Nil(err)
+	assert.Len(tasks, 60)
+	assert.Equal("c59", tasks[0].Id)
+	assert.Equal("c0", tasks[59].Id)
+	assert.Equal("pname1", tasks[0].ProjectName)
+	if assert.Len(queries, 2) {
+		assert.Equal("/api/v2/project/pid1/closed?from=2023-01-02+00%3A00%3A00&limit=50&status=Completed&to=2023-01-02+02%3A00%3A00", queries[0])
+		assert.Contains(queries[1], "to=2023-01-02+00%3A10%3A00")
+	}
+
+	// all the projects, most recent first
+	queries = nil
+	tasks, err = client.ListCompletedTasks(start, start.Add(5*time.Minute))
+	assert.Nil(err)
+	assert.Equal([]string{"c5", "c4", "c3", "c2", "c1", "other", "c0"}, ids(tasks))
+	assert.Len(queries, 1)
+	assert.Contains(queries[0], "/api/v2/project/all/closed")
+
+	// several projects
+	tasks, err = client.ListCompletedTasks(start, start.Add(time.Minute), "pid1", "pid2")
+	assert.Nil(err)
+	assert.Equal([]string{"c1", "other", "c0"}, ids(tasks))
+
+	// open range
+	tasks, err = client.ListCompletedTasks(time.Time{}, time.Time{})
+	assert.Nil(err)
+	assert.Len(tasks, 61)
+
+	// abandoned tasks
+	tasks, err = client.ListAbandonedTasks(start, start.Add(time.Hour))
+	assert.Nil(err)
+	assert.Empty(tasks)
+	assert.Contains(queries[len(queries)-1], "status=Abandoned")
+
+	// a task without completed time does not cut the list short
+	closed[30].CompletedTime = TickTickTime{}
+	tasks, err = client.ListCompletedTasks(time.Time{}, time.Time{}, "pid1")
+	assert.Nil(err)
+	assert.Len(tasks, 60)
+
+	// more tasks completed in the same second than in a page, the older ones are still listed
+	closed = nil
+	for i := 0; i < 3; i++ {
+		closed = append(closed, TaskItem{Id: fmt.Sprint("old", i), ProjectId: "pid1", Status: Completed, CompletedTime: NewTickTickTime(start.Add(time.Duration(i) * time.Minute))})
+	}
+	for i := 0; i < 60; i++ {
+		closed = append(closed, TaskItem{Id: fmt.Sprint("s", i), ProjectId: "pid1", Status: Completed, CompletedTime: NewTickTickTime(start.Add(time.Hour))})
+	}
+	queries = nil
+	tasks, err = client.ListCompletedTasks(start, start.Add(2*time.Hour), "pid1")
+	assert.True(errors.Is(err, ErrClosedTasksTruncated))
+	assert.ErrorContains(err, "pid1 at 2023-01-02 01:00:00")
+	assert.Len(tasks, 53)
+	assert.Equal([]string{"old2", "old1", "old0"}, ids(tasks)[50:])
+	if assert.Len(queries, 3) {
+		assert.Contains(queries[2], "to=2023-01-02+00%3A59%3A59")
+	}
+
+	// server error
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 404, Body: io.NopCloser(bytes.NewReader(nil)), Header: http.Header{}}, nil
+	})}
+	_, err = client.ListCompletedTasks(start, start.Add(time.Hour))
+	assert.NotNil(err)
+}
+
+func TestReopenTask(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+	task := TaskItem{Id: "1", Title: "test", ProjectId: "pid1", Status: Completed, CompletedTime: MustParseTickTickTime("2023-01-02T00:00:00.000+0000")}
+	reopened := task
+	reopened.Status = Open
+	reopened.CompletedTime = TickTickTime{}
+
+	// normal case
+	gock.New(baseUrlV2Test).
+		Post(fmt.Sprintf(taskUpdateUrlEndpoint, task.Id)).
+		MatchType("json").
+		JSON(reopened).
+		Reply(200).
+		JSON(reopened)
+	ntask, err := client.ReopenTask(&task)
+	assert.Nil(err)
+	assert.Equal(Open, ntask.Status)
+	assert.True(ntask.CompletedTime.IsZero())
+	assert.Equal(Completed, task.Status)
+
+	// task not created
+	_, err = client.ReopenTask(&TaskItem{Title: "test"})
+	assert.ErrorIs(err, ErrTaskNotCreated)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ticktick "github.com/ziyixi/go-ticktick"
+)
+
+func (a *app) login(args []string) error {
+	fs := a.flags()
+	userName := fs.String("u", "", "the username, else the one of the environment or the config file")
+	server := fs.String("server", "", "ticktick, dida365 or an api base url")
+	if _, err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	cfg, err := a.credentials()
+	if err != nil {
+		return err
+	}
+	if *userName != "" {
+		cfg.UserName = *userName
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if cfg.UserName == "" {
+		return fmt.Errorf("no username, use -u or set TICKTICK_USERNAME")
+	}
+	if cfg.PassWord == "" {
+		if cfg.PassWord, err = a.promptPassword(cfg.UserName); err != nil {
+			return err
+		}
+	}
+
+	store := signInStore{ticktick.NewFileTokenStore(a.tokenPath)}
+	client, err := ticktick.NewClientWithOptions(a.clientOptions(cfg, store)...)
+	if err != nil {
+		return err
+	}
+	a.client = client
+
+	// the password is only kept if it was already in the file
+	saved, err := a.readConfig()
+	if err != nil {
+		return err
+	}
+	saved.UserName, saved.Server = cfg.UserName, cfg.Server
+	if err := a.writeConfig(saved); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "signed in as %v\n", cfg.UserName)
+	return nil
+}
+
+func (a *app) sync(args []string) error {
+	if _, err := a.parse(a.flags(), args, 0, 0); err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.FullSync(); err != nil {
+		return err
+	}
+	tasks, err := client.QueryTasks(ticktick.Query())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "synced %v open tasks\n", len(tasks))
+	return nil
+}
+
+func (a *app) ls(args []string) error {
+	fs := a.flags()
+	projects := fs.String("project", "", "in one of the comma separated projects, by name or id")
+	tags := fs.String("tag", "", "with one of the comma separated tags")
+	title := fs.String("title", "", "with the text in the title")
+	priorities := fs.String("priority", "", "with one of the comma separated priorities: none, low, medium, high or 0, 1, 3, 5")
+	dueBefore := fs.String("due-before", "", "due before the date: today, tomorrow, 2006-01-02 or 2006-01-02 15:04")
+	dueAfter := fs.String("due-after", "", "due at or after the date")
+	noDue := fs.Bool("no-due", false, "without due date")
+	filter := fs.String("filter", "", "in the smart list with the name")
+	sortBy := fs.String("sort", "", "the order: due, start, priority, title or order, else the synced order")
+	limit := fs.Int("limit", 0, "at most this many tasks")
+	if _, err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	q := ticktick.Query()
+	if *projects != "" {
+		q = q.InProjects(splitList(*projects)...)
+	}
+	if *tags != "" {
+		q = q.WithAnyTag(splitList(*tags)...)
+	}
+	if *title != "" {
+		q = q.TitleContains(*title)
+	}
+	if *priorities != "" {
+		var values []int64
+		for _, s := range splitList(*priorities) {
+			p, err := parsePriority(s)
+			if err != nil {
+				return err
+			}
+			values = append(values, p)
+		}
+		q = q.Priority(values...)
+	}
+	if *dueBefore != "" {
+		date, _, err := a.parseDate(*dueBefore)
+		if err != nil {
+			return err
+		}
+		q = q.DueBefore(date)
+	}
+	if *dueAfter != "" {
+		date, _, err := a.parseDate(*dueAfter)
+		if err != nil {
+			return err
+		}
+		q = q.DueAfter(date)
+	}
+	if *noDue {
+		q = q.NoDueDate()
+	}
+	switch *sortBy {
+	case "":
+	case "due":
+		q = q.Sorted(ticktick.ByDue, ticktick.ByPriority)
+	case "start":
+		q = q.Sorted(ticktick.ByStart, ticktick.ByPriority)
+	case "priority":
+		q = q.Sorted(ticktick.ByPriority, ticktick.ByDue)
+	case "title":
+		q = q.Sorted(ticktick.ByTitle)
+	case "order":
+		q = q.Sorted(ticktick.BySortOrder)
+	default:
+		return fmt.Errorf("sort %v is not supported, use due, start, priority, title or order", *sortBy)
+	}
+	q = q.Limit(*limit)
+
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	if *filter != "" {
+		f, err := client.GetFilter(*filter)
+		if err != nil {
+			return err
+		}
+		fq, err := client.FilterQuery(f, a.now().In(a.loc))
+		if err != nil {
+			return err
+		}
+		q = q.And(fq)
+	}
+	tasks, err := client.QueryTasks(q)
+	if err != nil {
+		return err
+	}
+	return a.printTasks(tasks)
+}
+
+func (a *app) add(args []string) error {
+	fs := a.flags()
+	project := fs.String("project", "", "the project name, else the ^project of the title or the inbox")
+	content := fs.String("content", "", "the content")
+	tags := fs.String("tag", "", "the comma separated tags, on top of the #tags of the title")
+	priority := fs.String("priority", "", "none, low, medium, high or 0, 1, 3, 5")
+	start := fs.String("start", "", "the start date: today, tomorrow, 2006-01-02 or 2006-01-02 15:04")
+	due := fs.String("due", "", "the due date")
+	literal := fs.Bool("literal", false, "take the title as is, else it is parsed like the quick add of the app")
+	args, err := a.parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	title := strings.Join(args, " ")
+	var task *ticktick.TaskItem
+	if *literal {
+		task, err = ticktick.NewTask(client, title, "", time.Time{}, "")
+	} else {
+		task, err = ticktick.ParseQuickAdd(client, title, a.now(), a.loc)
+	}
+	if err != nil {
+		return err
+	}
+	task.Content = *content
+	if *project != "" {
+		// resolve the name
+		p, err := ticktick.NewTask(client, "", "", time.Time{}, *project)
+		if err != nil {
+			return err
+		}
+		if p.ProjectId != task.ProjectId {
+			// the ~section belongs to the project of the title
+			task.ColumnId = ""
+		}
+		task.ProjectId, task.ProjectName = p.ProjectId, p.ProjectName
+	}
+	if *priority != "" {
+		if task.Priority, err = parsePriority(*priority); err != nil {
+			return err
+		}
+	}
+	for _, tag := range splitList(*tags) {
+		if !ticktick.Contains(task.Tags, tag) {
+			task.Tags = append(task.Tags, tag)
+		}
+	}
+	if *start != "" {
+		date, allDay, err := a.parseDate(*start)
+		if err != nil {
+			return err
+		}
+		task.SetStart(date, allDay)
+	}
+	if *due != "" {
+		date, allDay, err := a.parseDate(*due)
+		if err != nil {
+			return err
+		}
+		task.SetDue(date, allDay)
+	}
+	task, err = client.CreateTask(task)
+	if err != nil {
+		return err
+	}
+	return a.printTasks([]ticktick.TaskItem{*task})
+}
+
+func (a *app) done(args []string) error {
+	return a.eachTask(args, func(client *ticktick.Client, t *ticktick.TaskItem) (*ticktick.TaskItem, error) {
+		return client.CompleteTask(t)
+	})
+}
+
+func (a *app) rm(args []string) error {
+	return a.eachTask(args, func(client *ticktick.Client, t *ticktick.TaskItem) (*ticktick.TaskItem, error) {
+		if _, err := client.DeleteTask(t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
+// run f on the tasks of the ids, print the tasks it returns
+func (a *app) eachTask(args []string, f func(client *ticktick.Client, t *ticktick.TaskItem) (*ticktick.TaskItem, error)) error {
+	args, err := a.parse(a.flags(), args, 1, -1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	var tasks []*ticktick.TaskItem
+	for _, ref := range args {
+		t, err := a.findTask(client, ref)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, t)
+	}
+	var done []ticktick.TaskItem
+	for _, t := range tasks {
+		newt, err := f(client, t)
+		if err != nil {
+			// still show the ones that went through
+			a.printTasks(done)
+			return fmt.Errorf("task %v: %w", t.Id, err)
+		}
+		done = append(done, *newt)
+	}
+	return a.printTasks(done)
+}
+
+func (a *app) mv(args []string) error {
+	args, err := a.parse(a.flags(), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	t, err := a.findTask(client, args[0])
+	if err != nil {
+		return err
+	}
+	t, err = client.MoveTask(t, args[1])
+	if err != nil {
+		return err
+	}
+	return a.printTasks([]ticktick.TaskItem{*t})
+}
+
+func (a *app) subtask(args []string) error {
+	args, err := a.parse(a.flags(), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	parent, err := a.findTask(client, args[0])
+	if err != nil {
+		return err
+	}
+	child, err := a.findTask(client, args[1])
+	if err != nil {
+		return err
+	}
+	parent, child, err = client.MakeSubtask(parent, child)
+	if err != nil {
+		return err
+	}
+	return a.printTasks([]ticktick.TaskItem{*parent, *child})
+}
+
+func (a *app) projects(args []string) error {
+	if _, err := a.parse(a.flags(), args, 0, 0); err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	projects, err := client.ListProjects()
+	if err != nil {
+		return err
+	}
+	return a.printProjects(projects)
+}
+
+// the open task with the id, or with the only id starting with ref
+func (a *app) findTask(client *ticktick.Client, ref string) (*ticktick.TaskItem, error) {
+	tasks, err := client.QueryTasks(ticktick.Query().Where(func(t *ticktick.TaskItem) bool {
+		return strings.HasPrefix(t.Id, ref)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		if tasks[i].Id == ref {
+			return &tasks[i], nil
+		}
+	}
+	switch len(tasks) {
+	case 0:
+		return nil, fmt.Errorf("%w: %v", ticktick.ErrTaskNotFound, ref)
+	case 1:
+		return &tasks[0], nil
+	}
+	return nil, fmt.Errorf("id %v is ambiguous, it starts %v task ids", ref, len(tasks))
+}
+
+// the date in the location of the app, and whether it has no time
+func (a *app) parseDate(s string) (time.Time, bool, error) {
+	now := a.now().In(a.loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, a.loc)
+	switch strings.ToLower(s) {
+	case "today":
+		return today, true, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true, nil
+	}
+	if date, err := time.ParseInLocation(dateLayout, s, a.loc); err == nil {
+		return date, true, nil
+	}
+	for _, layout := range []string{dateTimeLayout, "2006-01-02T15:04", time.RFC3339} {
+		if date, err := time.ParseInLocation(layout, s, a.loc); err == nil {
+			return date, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("date %v is not today, tomorrow, %v or %v", s, dateLayout, dateTimeLayout)
+}
+
+// the priority from its name or value
+func parsePriority(s string) (int64, error) {
+	for value, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return value, nil
+		}
+	}
+	if value, err := strconv.ParseInt(s, 10, 64); err == nil && priorityNames[value] != "" {
+		return value, nil
+	}
+	return 0, fmt.Errorf("priority %v is not none, low, medium, high, 0, 1, 3 or 5", s)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
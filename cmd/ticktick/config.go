@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ticktick "github.com/ziyixi/go-ticktick"
+	"golang.org/x/term"
+)
+
+// config is the config file, the environment variables override its fields
+type config struct {
+	UserName string `json:"username"`
+	PassWord string `json:"password,omitempty"` // better left out, the token cache is enough
+	Server   string `json:"server,omitempty"`   // ticktick, dida365 or an api base url
+}
+
+// the config file, a missing file is an empty config
+func (a *app) readConfig() (config, error) {
+	var cfg config
+	data, err := os.ReadFile(a.configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("config file %v is corrupted: %w", a.configPath, err)
+	}
+	return cfg, nil
+}
+
+func (a *app) writeConfig(cfg config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.configPath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(a.configPath, append(data, '\n'), 0o600)
+}
+
+// the config file with the environment variables on top
+func (a *app) credentials() (config, error) {
+	cfg, err := a.readConfig()
+	if err != nil {
+		return cfg, err
+	}
+	for env, field := range map[string]*string{
+		"TICKTICK_USERNAME": &cfg.UserName,
+		"TICKTICK_PASSWORD": &cfg.PassWord,
+		"TICKTICK_SERVER":   &cfg.Server,
+	} {
+		if v := a.getenv(env); v != "" {
+			*field = v
+		}
+	}
+	if cfg.Server == "" {
+		cfg.Server = "ticktick"
+	}
+	return cfg, nil
+}
+
+// the client options of the credentials, with the token cache
+func (a *app) clientOptions(cfg config, store ticktick.TokenStore) []ticktick.Option {
+	opts := []ticktick.Option{ticktick.WithCredentials(cfg.UserName, cfg.PassWord), ticktick.WithTokenStore(store)}
+	if strings.HasPrefix(cfg.Server, "http://") || strings.HasPrefix(cfg.Server, "https://") {
+		opts = append(opts, ticktick.WithBaseURL(cfg.Server))
+	} else {
+		opts = append(opts, ticktick.WithServer(cfg.Server))
+	}
+	return append(opts, a.options...)
+}
+
+// the signed in and synced client, with the cached token when there is one
+func (a *app) connect() (*ticktick.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	cfg, err := a.credentials()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.UserName == "" {
+		return nil, fmt.Errorf("no username, run ticktick login or set TICKTICK_USERNAME")
+	}
+	client, err := ticktick.NewClientWithOptions(a.clientOptions(cfg, ticktick.NewFileTokenStore(a.tokenPath))...)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return client, nil
+}
+
+// signInStore never has a token, so that the client signs in, and saves the new token to the cache
+type signInStore struct {
+	ticktick.TokenStore
+}
+
+func (signInStore) Load(string) (string, error) {
+	return "", nil
+}
+
+// read the password from the input, without echo on a terminal and as a plain line when piped
+func (a *app) promptPassword(userName string) (string, error) {
+	fmt.Fprintf(a.stderr, "password for %v: ", userName)
+	if f, ok := a.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(a.stderr)
+		if err != nil {
+			return "", fmt.Errorf("no password: %w", err)
+		}
+		return string(password), nil
+	}
+	line, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,167 @@
+// Command ticktick manages the TickTick and dida365 tasks from the terminal.
+//
+//	ticktick [-o table|json|yaml] [-config path] [-token-cache path] <command> [flags] [args]
+//
+// The credentials come from the TICKTICK_USERNAME, TICKTICK_PASSWORD and TICKTICK_SERVER
+// environment variables, else from the config file written by login, and the login token is
+// cached between the invocations so that the password is only needed to sign in again.
+// TICKTICK_SERVER is ticktick, dida365 or an api base url. TICKTICK_CONFIG and TICKTICK_TOKEN_CACHE
+// move the config file and the token cache from the user config and cache directories.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	ticktick "github.com/ziyixi/go-ticktick"
+)
+
+type command struct {
+	usage string // the arguments
+	help  string
+	run   func(a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"login":    {"[-u username] [-server server]", "sign in and save the username and the server to the config file", (*app).login},
+	"sync":     {"", "sync everything from scratch", (*app).sync},
+	"ls":       {"[flags]", "list the open tasks", (*app).ls},
+	"add":      {"[flags] title...", "create a task", (*app).add},
+	"done":     {"id...", "complete tasks", (*app).done},
+	"mv":       {"id project", "move a task to another project", (*app).mv},
+	"rm":       {"id...", "delete tasks", (*app).rm},
+	"subtask":  {"parent-id child-id", "make a task the subtask of another", (*app).subtask},
+	"projects": {"", "list the projects", (*app).projects},
+}
+
+// the usage was printed, there is nothing more to report
+var errUsage = errors.New("usage")
+
+// the flag package printed the error and the usage, -h is not an error
+func parseError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return flag.ErrHelp
+	}
+	return errUsage
+}
+
+// app is one invocation of the command
+type app struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	getenv         func(string) string
+	loc            *time.Location // of the dates given and shown
+	now            func() time.Time
+
+	output     string
+	configPath string
+	tokenPath  string
+	options    []ticktick.Option // for the tests
+
+	name   string // of the command
+	cmd    command
+	client *ticktick.Client
+}
+
+func main() {
+	a := &app{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+		loc:    time.Local,
+		now:    time.Now,
+	}
+	err := a.run(os.Args[1:])
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(a.stderr, "ticktick:", err)
+		os.Exit(1)
+	}
+}
+
+func (a *app) run(args []string) error {
+	fs := flag.NewFlagSet("ticktick", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.output, "o", "table", "the output format: table, json or yaml")
+	fs.StringVar(&a.configPath, "config", a.defaultPath(os.UserConfigDir, "TICKTICK_CONFIG", "config.json"), "the config file")
+	fs.StringVar(&a.tokenPath, "token-cache", a.defaultPath(os.UserCacheDir, "TICKTICK_TOKEN_CACHE", "tokens.json"), "the login token cache")
+	fs.Usage = func() { a.usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(a.stderr, "unknown command %v\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+	if err := a.checkOutput(); err != nil {
+		return err
+	}
+	a.name, a.cmd = fs.Arg(0), cmd
+	return cmd.run(a, fs.Args()[1:])
+}
+
+// the path in the environment variable, else the file in the ticktick directory of the user dir
+func (a *app) defaultPath(userDir func() (string, error), env, file string) string {
+	if path := a.getenv(env); path != "" {
+		return path
+	}
+	dir, err := userDir()
+	if err != nil {
+		return file
+	}
+	return filepath.Join(dir, "ticktick", file)
+}
+
+func (a *app) usage(fs *flag.FlagSet) {
+	fmt.Fprintln(a.stderr, "usage: ticktick [flags] <command> [flags] [args]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(a.stderr, "  %-9s %v\n", name, commands[name].help)
+	}
+	fmt.Fprintln(a.stderr, "\nflags:")
+	fs.PrintDefaults()
+}
+
+// the flags of the command, the output format can be given after the command too
+func (a *app) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(a.name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.output, "o", a.output, "the output format: table, json or yaml")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: ticktick %v %v\n\n%v\n", a.name, a.cmd.usage, a.cmd.help)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse the flags of a command and check the number of arguments, max < 0 is unlimited
+func (a *app) parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, parseError(err)
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), a.checkOutput()
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ticktick "github.com/ziyixi/go-ticktick"
+	"github.com/ziyixi/go-ticktick/tickticktest"
+	"gopkg.in/yaml.v3"
+)
+
+// testEnv runs the command against a fake server, every run is a new invocation
+// sharing the environment, the config file and the token cache
+type testEnv struct {
+	srv   *tickticktest.Server
+	work  ticktick.Project
+	env   map[string]string
+	stdin string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	srv := tickticktest.NewServer("testuser", "testpass")
+	t.Cleanup(srv.Close)
+	work := srv.AddProject("work")
+	srv.AddProject("home")
+	dir := t.TempDir()
+	return &testEnv{
+		srv:  srv,
+		work: work,
+		env: map[string]string{
+			"TICKTICK_SERVER":      srv.BaseURL(),
+			"TICKTICK_CONFIG":      filepath.Join(dir, "config.json"),
+			"TICKTICK_TOKEN_CACHE": filepath.Join(dir, "tokens.json"),
+		},
+	}
+}
+
+func (e *testEnv) run(args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	a := &app{
+		stdin:   strings.NewReader(e.stdin),
+		stdout:  &stdout,
+		stderr:  &stderr,
+		getenv:  func(key string) string { return e.env[key] },
+		loc:     time.UTC,
+		now:     func() time.Time { return time.Date(2023, 1, 4, 10, 0, 0, 0, time.UTC) },
+		options: []ticktick.Option{ticktick.WithRetryPolicy(ticktick.RetryPolicy{MaxAttempts: 1})},
+	}
+	err := a.run(args)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestLogin(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+
+	// no username
+	_, _, err := e.run("ls")
+	assert.ErrorContains(err, "no username")
+	_, _, err = e.run("login")
+	assert.ErrorContains(err, "no username")
+
+	// wrong password
+	e.stdin = "wrong\n"
+	_, _, err = e.run("login", "-u", "testuser")
+	var apiErr *ticktick.APIError
+	assert.True(errors.As(err, &apiErr))
+
+	// normal case, the password is prompted and not saved
+	e.stdin = "testpass\n"
+	out, stderr, err := e.run("login", "-u", "testuser")
+	assert.Nil(err)
+	assert.Equal("signed in as testuser\n", out)
+	assert.Contains(stderr, "password for testuser")
+	data, err := os.ReadFile(e.env["TICKTICK_CONFIG"])
+	assert.Nil(err)
+	assert.JSONEq(`{"username": "testuser", "server": "`+e.srv.BaseURL()+`"}`, string(data))
+	_, err = os.Stat(e.env["TICKTICK_TOKEN_CACHE"])
+	assert.Nil(err)
+
+	// the next invocations use the config file and the cached token
+	delete(e.env, "TICKTICK_SERVER")
+	e.stdin = ""
+	out, _, err = e.run("sync")
+	assert.Nil(err)
+	assert.Equal("synced 0 open tasks\n", out)
+
+	// without the password, the revoked token needs a new login
+	e.srv.RevokeToken()
+	_, _, err = e.run("sync")
+	assert.NotNil(err)
+	e.env["TICKTICK_PASSWORD"] = "testpass"
+	_, _, err = e.run("sync")
+	assert.Nil(err)
+}
+
+func TestTaskCommands(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	e.env["TICKTICK_USERNAME"] = "testuser"
+	e.env["TICKTICK_PASSWORD"] = "testpass"
+
+	// add
+	out, _, err := e.run("add", "-project", "work", "-tag", "report, urgent", "-priority", "high", "-due", "2023-01-06", "write", "report")
+	assert.Nil(err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if assert.Len(lines, 2) {
+		assert.Regexp(`^ID\s+PROJECT\s+PRIORITY\s+DUE\s+TAGS\s+TITLE$`, lines[0])
+		assert.Regexp(`^\w+\s+work\s+high\s+2023-01-06\s+report,urgent\s+write report$`, lines[1])
+	}
+	out, _, err = e.run("-o", "json", "add", "-due", "2023-01-05 17:30", "collect numbers")
+	assert.Nil(err)
+	var added []taskView
+	assert.Nil(json.Unmarshal([]byte(out), &added))
+	if !assert.Len(added, 1) {
+		return
+	}
+	assert.Equal("inbox", added[0].Project)
+	assert.Equal("2023-01-05 17:30", added[0].Due)
+	assert.Equal("none", added[0].Priority)
+	child := added[0].Id
+	out, _, err = e.run("add", "-o", "json", "-start", "tomorrow", "plan")
+	assert.Nil(err)
+	assert.Nil(json.Unmarshal([]byte(out), &added))
+	assert.Equal("2023-01-05", added[0].Start)
+
+	// the quick add syntax, the flags win
+	out, _, err = e.run("add", "-o", "json", "-priority", "medium", "call", "mom", "friday", "#family", "!low", "^home")
+	assert.Nil(err)
+	assert.Nil(json.Unmarshal([]byte(out), &added))
+	quick := added[0].Id
+	assert.Equal(taskView{Id: quick, Project: "home", Title: "call mom", Priority: "medium", Status: "open",
+		Start: "2023-01-06", Due: "2023-01-06", Tags: []string{"family"}}, added[0])
+	out, _, err = e.run("add", "-o", "json", "-literal", "call mom friday #family")
+	assert.Nil(err)
+	added = nil
+	assert.Nil(json.Unmarshal([]byte(out), &added))
+	assert.Equal("call mom friday #family", added[0].Title)
+	assert.Empty(added[0].Due)
+	_, _, err = e.run("rm", quick, added[0].Id)
+	assert.Nil(err)
+
+	// ls
+	out, _, err = e.run("ls", "-o", "json", "-sort", "due")
+	assert.Nil(err)
+	var tasks []taskView
+	assert.Nil(json.Unmarshal([]byte(out), &tasks))
+	assert.Equal([]string{"collect numbers", "write report", "plan"}, titles(tasks))
+	parent := tasks[1].Id
+	out, _, _ = e.run("ls", "-o", "json", "-tag", "urgent")
+	json.Unmarshal([]byte(out), &tasks)
+	assert.Equal([]string{"write report"}, titles(tasks))
+	out, _, _ = e.run("ls", "-o", "json", "-due-before", "tomorrow", "-priority", "none,low")
+	tasks = nil
+	json.Unmarshal([]byte(out), &tasks)
+	assert.Empty(tasks)
+	out, _, _ = e.run("ls", "-o", "json", "-due-after", "2023-01-05", "-project", "inbox")
+	json.Unmarshal([]byte(out), &tasks)
+	assert.Equal([]string{"collect numbers"}, titles(tasks))
+	out, _, _ = e.run("ls", "-o", "json", "-no-due", "-title", "PLAN")
+	json.Unmarshal([]byte(out), &tasks)
+	assert.Equal([]string{"plan"}, titles(tasks))
+
+	// mv and subtask
+	out, _, err = e.run("-o", "yaml", "mv", child, "home")
+	assert.Nil(err)
+	var moved []taskView
+	assert.Nil(yaml.Unmarshal([]byte(out), &moved))
+	if assert.Len(moved, 1) {
+		assert.Equal("home", moved[0].Project)
+	}
+	_, _, err = e.run("subtask", parent, child)
+	assert.Nil(err)
+	stored, _ := e.srv.Task(child)
+	assert.Equal(parent, stored.ParentId)
+	assert.Equal(e.work.Id, stored.ProjectId)
+
+	// done and rm
+	_, _, err = e.run("done", parent)
+	assert.Nil(err)
+	stored, _ = e.srv.Task(parent)
+	assert.Equal(ticktick.Completed, stored.Status)
+	out, _, err = e.run("rm", child)
+	assert.Nil(err)
+	assert.Contains(out, "collect numbers")
+	_, ok := e.srv.Task(child)
+	assert.False(ok)
+	_, _, err = e.run("rm", child)
+	assert.True(errors.Is(err, ticktick.ErrTaskNotFound))
+
+	// projects
+	out, _, err = e.run("projects", "-o", "yaml")
+	assert.Nil(err)
+	var projects []projectView
+	assert.Nil(yaml.Unmarshal([]byte(out), &projects))
+	assert.Len(projects, 2)
+	assert.Contains(out, "name: work")
+}
+
+func TestUsage(t *testing.T) {
+	assert := assert.New(t)
+	e := newTestEnv(t)
+	e.env["TICKTICK_USERNAME"] = "testuser"
+	e.env["TICKTICK_PASSWORD"] = "testpass"
+
+	// no command, unknown command and wrong arguments
+	_, stderr, err := e.run()
+	assert.True(errors.Is(err, errUsage))
+	assert.Contains(stderr, "subtask")
+	_, stderr, err = e.run("random")
+	assert.True(errors.Is(err, errUsage))
+	assert.Contains(stderr, "unknown command random")
+	_, stderr, err = e.run("mv", "a")
+	assert.True(errors.Is(err, errUsage))
+	assert.Contains(stderr, "usage: ticktick mv id project")
+	_, _, err = e.run("ls", "-random")
+	assert.True(errors.Is(err, errUsage))
+	_, _, err = e.run("ls", "-h")
+	assert.True(errors.Is(err, flag.ErrHelp))
+
+	// wrong values
+	_, _, err = e.run("-o", "xml", "ls")
+	assert.ErrorContains(err, "output format xml")
+	_, _, err = e.run("ls", "-priority", "2")
+	assert.ErrorContains(err, "priority 2")
+	_, _, err = e.run("ls", "-due-before", "someday")
+	assert.ErrorContains(err, "date someday")
+	_, _, err = e.run("ls", "-sort", "random")
+	assert.ErrorContains(err, "sort random")
+	_, _, err = e.run("ls", "-filter", "random")
+	assert.True(errors.Is(err, ticktick.ErrFilterNotFound))
+	_, _, err = e.run("add", "-project", "random", "a")
+	assert.True(errors.Is(err, ticktick.ErrProjectNotFound))
+
+	// ambiguous id
+	e.srv.AddTask(ticktick.TaskItem{Title: "a"})
+	e.srv.AddTask(ticktick.TaskItem{Title: "b"})
+	_, _, err = e.run("done", "")
+	assert.ErrorContains(err, "ambiguous")
+}
+
+func titles(tasks []taskView) []string {
+	var res []string
+	for _, t := range tasks {
+		res = append(res, t.Title)
+	}
+	return res
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ticktick "github.com/ziyixi/go-ticktick"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04"
+)
+
+// taskView is a task as printed, the same fields in every format
+type taskView struct {
+	Id       string   `json:"id" yaml:"id"`
+	Project  string   `json:"project" yaml:"project"`
+	ParentId string   `json:"parentId,omitempty" yaml:"parentId,omitempty"`
+	Title    string   `json:"title" yaml:"title"`
+	Content  string   `json:"content,omitempty" yaml:"content,omitempty"`
+	Priority string   `json:"priority" yaml:"priority"`
+	Status   string   `json:"status" yaml:"status"`
+	Start    string   `json:"start,omitempty" yaml:"start,omitempty"`
+	Due      string   `json:"due,omitempty" yaml:"due,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Repeat   string   `json:"repeat,omitempty" yaml:"repeat,omitempty"`
+}
+
+type projectView struct {
+	Id       string `json:"id" yaml:"id"`
+	Name     string `json:"name" yaml:"name"`
+	Kind     string `json:"kind" yaml:"kind"`
+	ViewMode string `json:"viewMode" yaml:"viewMode"`
+	Archived bool   `json:"archived" yaml:"archived"`
+}
+
+var priorityNames = map[int64]string{0: "none", 1: "low", 3: "medium", 5: "high"}
+
+var statusNames = map[int64]string{ticktick.Open: "open", ticktick.Completed: "completed", ticktick.Abandoned: "abandoned"}
+
+func (a *app) taskView(t ticktick.TaskItem) taskView {
+	return taskView{
+		Id:       t.Id,
+		Project:  t.ProjectName,
+		ParentId: t.ParentId,
+		Title:    t.Title,
+		Content:  t.Content,
+		Priority: priorityNames[t.Priority],
+		Status:   statusNames[t.Status],
+		Start:    a.formatDate(t.StartIn(a.loc), t.IsAllDay),
+		Due:      a.formatDate(t.DueIn(a.loc), t.IsAllDay),
+		Tags:     t.Tags,
+		Repeat:   t.Repeat,
+	}
+}
+
+func (a *app) formatDate(date time.Time, allDay bool) string {
+	switch {
+	case date.IsZero():
+		return ""
+	case allDay:
+		return date.Format(dateLayout)
+	}
+	return date.Format(dateTimeLayout)
+}
+
+func (a *app) checkOutput() error {
+	switch a.output {
+	case "table", "json", "yaml":
+		return nil
+	}
+	return fmt.Errorf("output format %v is not supported, use table, json or yaml", a.output)
+}
+
+func (a *app) printTasks(tasks []ticktick.TaskItem) error {
+	views := make([]taskView, 0, len(tasks))
+	for _, t := range tasks {
+		views = append(views, a.taskView(t))
+	}
+	return a.print(views, len(views), []string{"ID", "PROJECT", "PRIORITY", "DUE", "TAGS", "TITLE"}, func(i int) []string {
+		v := views[i]
+		return []string{v.Id, v.Project, v.Priority, v.Due, strings.Join(v.Tags, ","), v.Title}
+	})
+}
+
+func (a *app) printProjects(projects []ticktick.Project) error {
+	views := make([]projectView, 0, len(projects))
+	for _, p := range projects {
+		views = append(views, projectView{Id: p.Id, Name: p.Name, Kind: p.Kind, ViewMode: p.ViewMode, Archived: p.Closed})
+	}
+	return a.print(views, len(views), []string{"ID", "NAME", "KIND", "VIEW", "ARCHIVED"}, func(i int) []string {
+		v := views[i]
+		archived := ""
+		if v.Archived {
+			archived = "yes"
+		}
+		return []string{v.Id, v.Name, v.Kind, v.ViewMode, archived}
+	})
+}
+
+// print the n views in the output format, row gives the cells of the i-th view in a table
+func (a *app) print(views any, n int, header []string, row func(i int) []string) error {
+	switch a.output {
+	case "json":
+		data, err := json.MarshalIndent(views, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(a.stdout, "%s\n", data)
+		return err
+	case "yaml":
+		enc := yaml.NewEncoder(a.stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(views); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for i := 0; i < n; i++ {
+		fmt.Fprintln(w, strings.Join(row(i), "\t"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,84 @@
+package ticktick
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+var (
+	// the server rejects the login token, even after signing in again
+	ErrUnauthorized = errors.New("unauthorized")
+	// the project name or id is not in the synced projects
+	ErrProjectNotFound = errors.New("project not found")
+	// the section name is not in the sections of the project
+	ErrSectionNotFound = errors.New("section not found")
+	// the task has no id yet, create it first
+	ErrTaskNotCreated = errors.New("task has not been created")
+	// the task already has an id, it can not be created again
+	ErrTaskAlreadyCreated = errors.New("the task has already been created")
+	// the task is not in the synced tasks
+	ErrTaskNotFound = errors.New("task not found")
+	// the checklist item is not in the task
+	ErrChecklistItemNotFound = errors.New("checklist item not found")
+	// the filter is not in the synced filters
+	ErrFilterNotFound = errors.New("filter not found")
+	// the filter rule has a condition that can not be evaluated locally
+	ErrUnsupportedFilter = errors.New("unsupported filter rule")
+	// the reminder can not be parsed, or the server would not accept it
+	ErrInvalidReminder = errors.New("invalid reminder")
+	// the repeat rule can not be parsed
+	ErrInvalidRepeat = errors.New("invalid repeat rule")
+	// the repeat rule is valid but can not be expanded locally
+	ErrUnsupportedRepeat = errors.New("unsupported repeat rule")
+	// the completed and abandoned tasks are not synced, they can not be queried
+	ErrClosedTasksNotSynced = errors.New("closed tasks are not synced")
+	// more closed tasks share a second than fit in a page, the api can not page through them
+	ErrClosedTasksTruncated = errors.New("closed tasks truncated")
+	// the iCalendar data can not be parsed
+	ErrInvalidICS = errors.New("invalid icalendar data")
+)
+
+// APIError is returned when the server answers with a non 2xx status.
+// ErrorCode and ErrorMessage come from the json error body, when there is one.
+type APIError struct {
+	StatusCode   int
+	ErrorCode    string
+	ErrorMessage string
+	Body         string
+	RetryAfter   time.Duration // from the Retry-After header, zero if absent
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorCode == "" && e.ErrorMessage == "" {
+		return fmt.Sprintf("ticktick api error: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("ticktick api error: status %d, %v: %v", e.StatusCode, e.ErrorCode, e.ErrorMessage)
+}
+
+// the response validator of every request, turning the non 2xx responses into *APIError
+func checkAPIError(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+	}
+	if gjson.ValidBytes(body) {
+		apiErr.ErrorCode = gjson.GetBytes(body, "errorCode").String()
+		apiErr.ErrorMessage = gjson.GetBytes(body, "errorMessage").String()
+	}
+	return apiErr
+}
+
+func isUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
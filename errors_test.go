@@ -0,0 +1,69 @@
+package ticktick
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := &Client{
+		UserName:       "testuser",
+		PassWord:       "testpass",
+		baseUrlV2:      baseUrlV2Test,
+		projectName2Id: make(map[string]string),
+		id2ProjectName: make(map[string]string),
+	}
+
+	// json error body
+	gock.New(baseUrlV2Test).
+		Post(signinUrlEndpoint).
+		Reply(400).
+		JSON(map[string]any{
+			"errorId":      "abc",
+			"errorCode":    "username_password_not_match",
+			"errorMessage": "username or password error",
+			"data":         nil,
+		})
+	err := client.GetToken()
+	var apiErr *APIError
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(400, apiErr.StatusCode)
+		assert.Equal("username_password_not_match", apiErr.ErrorCode)
+		assert.Equal("username or password error", apiErr.ErrorMessage)
+		assert.Contains(apiErr.Body, "errorId")
+		assert.Contains(fmt.Sprint(err), "status 400, username_password_not_match: username or password error")
+	}
+
+	// plain text error body
+	gock.New(baseUrlV2Test).
+		Post(signinUrlEndpoint).
+		Reply(502).
+		BodyString("bad gateway")
+	err = client.GetToken()
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(502, apiErr.StatusCode)
+		assert.Empty(apiErr.ErrorCode)
+		assert.Equal("bad gateway", apiErr.Body)
+		assert.Contains(fmt.Sprint(err), "status 502")
+	}
+
+	// unauthorized errors keep the api error
+	client.PassWord = ""
+	client.loginToken = "expiredtoken"
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(401).
+		JSON(map[string]string{"errorCode": "user_not_sign_on"})
+	err = client.Sync()
+	assert.True(errors.Is(err, ErrUnauthorized))
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(401, apiErr.StatusCode)
+		assert.Equal("user_not_sign_on", apiErr.ErrorCode)
+	}
+}
@@ -0,0 +1,301 @@
+package ticktick
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter is a custom smart list of the app, its tasks are the open tasks matching Rule
+type Filter struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Rule      string `json:"rule"` // the rule tree as a json string, see FilterQuery
+	SortOrder int64  `json:"sortOrder"`
+	SortType  string `json:"sortType"` // dueDate, priority, title, sortOrder or project
+	ViewMode  string `json:"viewMode"` // list, kanban or timeline
+}
+
+// a node of the rule tree: a condition on one field when it has a conditionName,
+// with values in and, or or not, else a group of nodes
+type filterRule struct {
+	ConditionName string            `json:"conditionName"`
+	And           []json.RawMessage `json:"and"`
+	Or            []json.RawMessage `json:"or"`
+	Not           []json.RawMessage `json:"not"`
+}
+
+// the synced data a rule is evaluated with
+type filterEnv struct {
+	now        time.Time
+	userId     string
+	groupOf    map[string]string // project id to project group id
+	tagParents map[string]string // tag name to parent tag name
+}
+
+// the synced filters, after syncing
+func (c *Client) ListFilters() ([]Filter, error) {
+	return c.ListFiltersCtx(context.Background())
+}
+
+// ListFiltersCtx is ListFilters with a context
+func (c *Client) ListFiltersCtx(ctx context.Context) ([]Filter, error) {
+	if err := c.SyncCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Filter(nil), c.filters...), nil
+}
+
+// find a synced filter by name
+func (c *Client) GetFilter(name string) (*Filter, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, f := range c.filters {
+		if f.Name == name {
+			newf := f
+			return &newf, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrFilterNotFound, name)
+}
+
+// The tasks of the smart list, like the app shows them, after syncing
+func (c *Client) FilterTasks(f *Filter) ([]TaskItem, error) {
+	return c.FilterTasksCtx(context.Background(), f)
+}
+
+// FilterTasksCtx is FilterTasks with a context
+func (c *Client) FilterTasksCtx(ctx context.Context, f *Filter) ([]TaskItem, error) {
+	// the query is built from the synced projects and tags, so sync first
+	if err := c.syncForQuery(ctx); err != nil {
+		return nil, err
+	}
+	q, err := c.FilterQuery(f, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return c.queryTasks(q)
+}
+
+// The query of the smart list, with the dates relative to now in its location.
+// The rule conditions are list (project or project group ids), tag (with their subtags,
+// "!tag" for the untagged tasks), dueDate (nodue, overdue, today, tomorrow, thisweek,
+// nextweek, thismonth and nextmonth, the weeks start on Monday), priority, assignee
+// (me, other, noassignee or user ids, me needs a client that signed in) and keywords
+// (in the title, the content or the description). The other conditions return ErrUnsupportedFilter.
+func (c *Client) FilterQuery(f *Filter, now time.Time) (*TaskQuery, error) {
+	env := filterEnv{now: now, groupOf: make(map[string]string), tagParents: make(map[string]string)}
+	c.mu.RLock()
+	env.userId = c.userId
+	for _, p := range c.projects {
+		env.groupOf[p.Id] = p.GroupId
+	}
+	for _, t := range c.tags {
+		env.tagParents[t.Name] = tagName(t.Parent)
+	}
+	c.mu.RUnlock()
+
+	q, err := env.build(json.RawMessage(f.Rule))
+	if err != nil {
+		return nil, fmt.Errorf("filter %v: %w", f.Name, err)
+	}
+	q = Query().Status(Open).And(q)
+	switch f.SortType {
+	case "dueDate":
+		q = q.Sorted(ByDue)
+	case "priority":
+		q = q.Sorted(ByPriority)
+	case "title":
+		q = q.Sorted(ByTitle)
+	case "sortOrder":
+		q = q.Sorted(BySortOrder)
+	}
+	return q, nil
+}
+
+// match no task, the start of the or groups
+func noTask() *TaskQuery {
+	return Query().Where(func(*TaskItem) bool { return false })
+}
+
+func (env *filterEnv) build(raw json.RawMessage) (*TaskQuery, error) {
+	var rule filterRule
+	if err := json.Unmarshal(raw, &rule); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFilter, err)
+	}
+	node := env.build
+	if rule.ConditionName != "" {
+		node = func(value json.RawMessage) (*TaskQuery, error) {
+			return env.condition(rule.ConditionName, value)
+		}
+	}
+	children := func(raws []json.RawMessage) ([]*TaskQuery, error) {
+		var queries []*TaskQuery
+		for _, r := range raws {
+			q, err := node(r)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, q)
+		}
+		return queries, nil
+	}
+
+	q := Query()
+	and, err := children(rule.And)
+	if err != nil {
+		return nil, err
+	}
+	q = q.And(and...)
+	if len(rule.Or) > 0 {
+		or, err := children(rule.Or)
+		if err != nil {
+			return nil, err
+		}
+		q = q.And(noTask().Or(or...))
+	}
+	not, err := children(rule.Not)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range not {
+		q = q.Not(n)
+	}
+	return q, nil
+}
+
+// the tasks matching one value of a condition
+func (env *filterEnv) condition(name string, raw json.RawMessage) (*TaskQuery, error) {
+	if name == "priority" {
+		var priority int64
+		if err := json.Unmarshal(raw, &priority); err != nil {
+			return nil, fmt.Errorf("%w: priority %s", ErrUnsupportedFilter, raw)
+		}
+		return Query().Priority(priority), nil
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("%w: %v %s", ErrUnsupportedFilter, name, raw)
+	}
+
+	switch name {
+	case "list":
+		return Query().Where(func(t *TaskItem) bool {
+			return t.ProjectId == value || (env.groupOf[t.ProjectId] != "" && env.groupOf[t.ProjectId] == value)
+		}), nil
+	case "tag":
+		if value == "!tag" {
+			return Query().Untagged(), nil
+		}
+		return Query().Where(func(t *TaskItem) bool {
+			for _, tag := range t.Tags {
+				// the tags nest one level, the depth guards against cycles
+				for name, depth := tagName(tag), 0; name != "" && depth < 2; name, depth = env.tagParents[name], depth+1 {
+					if name == tagName(value) {
+						return true
+					}
+				}
+			}
+			return false
+		}), nil
+	case "keywords":
+		value = strings.ToLower(value)
+		return Query().Where(func(t *TaskItem) bool {
+			for _, text := range []string{t.Title, t.Content, t.Desc} {
+				if strings.Contains(strings.ToLower(text), value) {
+					return true
+				}
+			}
+			return false
+		}), nil
+	case "assignee":
+		return env.assignee(value)
+	case "dueDate":
+		return env.dueDate(value)
+	}
+	return nil, fmt.Errorf("%w: condition %v", ErrUnsupportedFilter, name)
+}
+
+func (env *filterEnv) assignee(value string) (*TaskQuery, error) {
+	if value == "me" {
+		if env.userId == "" {
+			return nil, fmt.Errorf("%w: the user id is unknown, sign in to use the assignee me", ErrUnsupportedFilter)
+		}
+		value = env.userId
+	}
+	return Query().Where(func(t *TaskItem) bool {
+		assignee := taskAssignee(t)
+		switch value {
+		case "noassignee":
+			return assignee == ""
+		case "other":
+			return assignee != "" && assignee != env.userId
+		}
+		return assignee == value
+	}), nil
+}
+
+// the assignee is not modeled, it is a user id in the unknown fields
+func taskAssignee(t *TaskItem) string {
+	raw, ok := t.unknownFields["assignee"]
+	if !ok {
+		return ""
+	}
+	assignee := strings.Trim(string(raw), `"`)
+	if assignee == "null" || assignee == "-1" {
+		return ""
+	}
+	return assignee
+}
+
+func (env *filterEnv) dueDate(value string) (*TaskQuery, error) {
+	loc := env.now.Location()
+	y, m, d := env.now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	month := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+
+	// the due date of the task in loc, or its start date
+	date := func(t *TaskItem) time.Time {
+		if due := t.DueIn(loc); !due.IsZero() {
+			return due
+		}
+		return t.StartIn(loc)
+	}
+	between := func(from, to time.Time) *TaskQuery {
+		return Query().Where(func(t *TaskItem) bool {
+			d := date(t)
+			return !d.IsZero() && !d.Before(from) && d.Before(to)
+		})
+	}
+
+	switch value {
+	case "nodue":
+		return Query().Where(func(t *TaskItem) bool { return date(t).IsZero() }), nil
+	case "overdue":
+		return Query().Where(func(t *TaskItem) bool {
+			d := date(t)
+			if t.IsAllDay {
+				return !d.IsZero() && d.Before(today)
+			}
+			return !d.IsZero() && d.Before(env.now)
+		}), nil
+	case "today":
+		return between(today, today.AddDate(0, 0, 1)), nil
+	case "tomorrow":
+		return between(today.AddDate(0, 0, 1), today.AddDate(0, 0, 2)), nil
+	case "thisweek":
+		return between(monday, monday.AddDate(0, 0, 7)), nil
+	case "nextweek":
+		return between(monday.AddDate(0, 0, 7), monday.AddDate(0, 0, 14)), nil
+	case "thismonth":
+		return between(month, month.AddDate(0, 1, 0)), nil
+	case "nextmonth":
+		return between(month.AddDate(0, 1, 0), month.AddDate(0, 2, 0)), nil
+	}
+	return nil, fmt.Errorf("%w: dueDate %v", ErrUnsupportedFilter, value)
+}
@@ -0,0 +1,181 @@
+package ticktick
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func BuildFilterClient() *Client {
+	NewSignInTestServer()
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(200).
+		JSON(map[string]any{
+			"checkPoint":      1,
+			"inboxId":         "inbox1",
+			"projectProfiles": []map[string]any{{"id": "pid1", "name": "work", "groupId": "gid1"}, {"id": "pid2", "name": "home"}},
+			"tags":            []map[string]any{{"name": "urgent", "label": "Urgent"}, {"name": "fire", "label": "Fire", "parent": "urgent"}},
+			"filters": []map[string]any{
+				{"id": "f1", "name": "Urgent work", "sortType": "priority", "viewMode": "list", "sortOrder": 1,
+					"rule": `{"and":[{"or":["gid1"],"conditionName":"list"},{"or":["urgent"],"conditionName":"tag"}],"type":0,"version":1}`},
+			},
+			"syncTaskBean": map[string]any{"update": []map[string]any{
+				{"id": "1", "title": "deploy", "projectId": "pid1", "tags": []string{"urgent"}, "priority": 1},
+				{"id": "2", "title": "fix outage", "projectId": "pid1", "tags": []string{"fire"}, "priority": 5, "assignee": "testuserid"},
+				{"id": "3", "title": "groceries", "projectId": "pid2", "tags": []string{"urgent"}, "assignee": 42,
+					"dueDate": "2023-01-05T16:00:00.000+0000", "isAllDay": true, "timeZone": "Asia/Shanghai"},
+				{"id": "4", "title": "call plumber", "projectId": "pid2", "content": "the SINK leaks",
+					"dueDate": "2023-01-06T09:00:00.000+0000", "timeZone": "UTC"},
+				{"id": "5", "title": "write notes", "projectId": "pid1", "status": 2},
+			}},
+		})
+	client, _ := NewClient("testuser", "testpass", "test")
+	return client
+}
+
+func TestListFilters(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildFilterClient()
+
+	// normal case
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 1)).
+		Reply(200).
+		JSON(map[string]any{"checkPoint": 2, "filters": []map[string]any{{"id": "f1", "name": "Urgent work", "rule": "{}"}, {"id": "f2", "name": "Later", "rule": "{}"}}})
+	filters, err := client.ListFilters()
+	assert.Nil(err)
+	assert.Equal([]Filter{{Id: "f1", Name: "Urgent work", Rule: "{}"}, {Id: "f2", Name: "Later", Rule: "{}"}}, filters)
+
+	f, err := client.GetFilter("Later")
+	assert.Nil(err)
+	assert.Equal("f2", f.Id)
+
+	// filter not found
+	_, err = client.GetFilter("random")
+	assert.True(errors.Is(err, ErrFilterNotFound))
+
+	// sync error
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 2)).
+		Reply(404)
+	_, err = client.ListFilters()
+	assert.NotNil(err)
+}
+
+func TestFilterTasks(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildFilterClient()
+
+	// normal case, project groups and subtags match, sorted by priority
+	f, err := client.GetFilter("Urgent work")
+	assert.Nil(err)
+	assert.Equal("priority", f.SortType)
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 1)).
+		Reply(200).
+		JSON(map[string]any{"checkPoint": 2})
+	tasks, err := client.FilterTasks(f)
+	assert.Nil(err)
+	assert.Equal([]string{"2", "1"}, ids(tasks))
+
+	// the tags synced just before are known to the rule
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 2)).
+		Reply(200).
+		JSON(map[string]any{
+			"checkPoint": 3,
+			"tags": []map[string]any{{"name": "urgent", "label": "Urgent"}, {"name": "fire", "label": "Fire", "parent": "urgent"},
+				{"name": "blaze", "label": "Blaze", "parent": "urgent"}},
+			"syncTaskBean": map[string]any{"add": []map[string]any{{"id": "6", "title": "reboot", "projectId": "pid1", "tags": []string{"blaze"}}}},
+		})
+	tasks, err = client.FilterTasks(f)
+	assert.Nil(err)
+	assert.Equal([]string{"2", "1", "6"}, ids(tasks))
+	assert.True(gock.IsDone())
+
+	// unsupported rules
+	_, err = client.FilterTasks(&Filter{Rule: `{"and":[{"or":["x"],"conditionName":"location"}]}`})
+	assert.True(errors.Is(err, ErrUnsupportedFilter))
+}
+
+func TestFilterQuery(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildFilterClient()
+	client.mu.RLock()
+	tasks := append([]TaskItem(nil), client.tasks...)
+	client.mu.RUnlock()
+
+	// a Thursday, in Shanghai the 3rd task is due on the 6th
+	now := time.Date(2023, 1, 5, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		rule string
+		want []string
+	}{
+		{`{"and":[{"or":["pid2"],"conditionName":"list"}]}`, []string{"3", "4"}},
+		{`{"and":[{"not":["pid2"],"conditionName":"list"}]}`, []string{"1", "2"}},
+		{`{"and":[{"or":["fire"],"conditionName":"tag"}]}`, []string{"2"}},
+		{`{"and":[{"or":["!tag"],"conditionName":"tag"}]}`, []string{"4"}},
+		{`{"and":[{"and":["urgent","fire"],"conditionName":"tag"}]}`, []string{"2"}},
+		{`{"and":[{"or":[5,1],"conditionName":"priority"}]}`, []string{"1", "2"}},
+		{`{"and":[{"or":["sink"],"conditionName":"keywords"}]}`, []string{"4"}},
+		{`{"and":[{"or":["me"],"conditionName":"assignee"}]}`, []string{"2"}},
+		{`{"and":[{"or":["other"],"conditionName":"assignee"}]}`, []string{"3"}},
+		{`{"and":[{"or":["noassignee"],"conditionName":"assignee"}]}`, []string{"1", "4"}},
+		{`{"and":[{"or":["42"],"conditionName":"assignee"}]}`, []string{"3"}},
+		{`{"and":[{"or":["nodue"],"conditionName":"dueDate"}]}`, []string{"1", "2"}},
+		{`{"and":[{"or":["today"],"conditionName":"dueDate"}]}`, nil},
+		{`{"and":[{"or":["tomorrow"],"conditionName":"dueDate"}]}`, []string{"3", "4"}},
+		{`{"and":[{"or":["overdue"],"conditionName":"dueDate"}]}`, nil},
+		{`{"and":[{"or":["thisweek"],"conditionName":"dueDate"}]}`, []string{"3", "4"}},
+		{`{"and":[{"or":["nextweek","nextmonth"],"conditionName":"dueDate"}]}`, nil},
+		{`{"and":[{"or":["thismonth"],"conditionName":"dueDate"}]}`, []string{"3", "4"}},
+		{`{"or":[{"or":["pid1"],"conditionName":"list"},{"or":["sink"],"conditionName":"keywords"}]}`, []string{"1", "2", "4"}},
+		{`{"and":[{"or":[{"or":["fire"],"conditionName":"tag"},{"or":[0],"conditionName":"priority"}]},{"not":[{"or":["pid2"],"conditionName":"list"}]}]}`, []string{"2"}},
+	}
+	for _, tc := range testCases {
+		q, err := client.FilterQuery(&Filter{Rule: tc.rule}, now)
+		if assert.Nil(err, tc.rule) {
+			assert.Equal(tc.want, ids(q.Apply(tasks)), tc.rule)
+		}
+	}
+
+	// overdue, in the location of now
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	q, _ := client.FilterQuery(&Filter{Rule: `{"and":[{"or":["overdue"],"conditionName":"dueDate"}]}`}, time.Date(2023, 1, 7, 0, 0, 0, 0, shanghai))
+	assert.Equal([]string{"3", "4"}, ids(q.Apply(tasks)))
+	q, _ = client.FilterQuery(&Filter{Rule: `{"and":[{"or":["overdue"],"conditionName":"dueDate"}]}`}, time.Date(2023, 1, 6, 18, 0, 0, 0, shanghai))
+	assert.Equal([]string{"4"}, ids(q.Apply(tasks)))
+
+	// the sort types
+	q, _ = client.FilterQuery(&Filter{Rule: `{}`, SortType: "dueDate"}, now)
+	assert.Equal([]string{"3", "4", "1", "2"}, ids(q.Apply(tasks)))
+	q, _ = client.FilterQuery(&Filter{Rule: `{}`, SortType: "title"}, now)
+	assert.Equal([]string{"4", "1", "2", "3"}, ids(q.Apply(tasks)))
+
+	// unsupported rules
+	for _, rule := range []string{
+		``,
+		`{"and":[{"or":["x"],"conditionName":"location"}]}`,
+		`{"and":[{"or":["someday"],"conditionName":"dueDate"}]}`,
+		`{"and":[{"or":["high"],"conditionName":"priority"}]}`,
+		`{"and":[{"or":[1],"conditionName":"tag"}]}`,
+		`{"and":[{"or":[{"or":["x"],"conditionName":"location"}]}]}`,
+		`{"and":[{"not":[{"or":["x"],"conditionName":"location"}]}]}`,
+	} {
+		_, err := client.FilterQuery(&Filter{Rule: rule}, now)
+		assert.True(errors.Is(err, ErrUnsupportedFilter), rule)
+	}
+
+	// the assignee me needs the user id
+	client.userId = ""
+	_, err := client.FilterQuery(&Filter{Rule: `{"and":[{"or":["me"],"conditionName":"assignee"}]}`}, now)
+	assert.True(errors.Is(err, ErrUnsupportedFilter))
+}
@@ -0,0 +1,789 @@
+package ticktick
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icsProductId  = "-//ziyixi//go-ticktick//EN"
+	icsLineLength = 75 // in octets, the longer content lines are folded
+
+	// the TickTick fields that iCalendar has no place for
+	icsRepeatProperty     = "X-TICKTICK-REPEAT"
+	icsRepeatFromProperty = "X-TICKTICK-REPEAT-FROM"
+)
+
+// the PRIORITY of the task priorities, 1 is the highest and 0 is undefined
+var icsPriorities = map[int64]int{5: 1, 3: 5, 1: 9}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// ExportICS writes the tasks as an iCalendar (RFC 5545). The open tasks with a time span, timed
+// and due after their start, are VEVENTs so that the calendars show them as blocks, the other
+// tasks are VTODOs:
+//
+//   - UID is the task id, SUMMARY the title and DESCRIPTION the content
+//   - DTSTART and DUE (DTEND for the events) are dates for the all day tasks, else times in the
+//     TZID of the task time zone or in UTC. A task starting when it is due only has DUE.
+//   - RRULE is Repeat without the TickTick extensions, TT_WORKDAY becomes BYSETPOS on the
+//     weekdays and the custom dates are RDATE. Repeat is also kept in X-TICKTICK-REPEAT
+//     when the RRULE is not the same rule.
+//   - a VALARM for each reminder, relative to DUE for the to-dos
+//   - PRIORITY is 1, 5 and 9 for the high, medium and low priorities
+//   - CATEGORIES are the tags and RELATED-TO the parent id
+//   - STATUS and COMPLETED for the completed and the abandoned tasks
+//
+// The TZIDs are IANA names without VTIMEZONE, which most calendars accept (RFC 7809).
+// X-WR-TIMEZONE is the time zone of the tasks when they share one, the dates are in it.
+func ExportICS(w io.Writer, tasks []TaskItem) error {
+	e := &icsWriter{w: bufio.NewWriter(w)}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", icsProductId)
+	e.line("CALSCALE", "GREGORIAN")
+	if zone := icsCommonZone(tasks); zone != "" {
+		e.line("X-WR-TIMEZONE", zone)
+	}
+	for i := range tasks {
+		e.task(&tasks[i])
+	}
+	e.line("END", "VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// write a content line with its params like "TZID=Asia/Shanghai", folded at icsLineLength
+func (e *icsWriter) line(name, value string, params ...string) {
+	if e.err != nil {
+		return
+	}
+	s := name
+	for _, p := range params {
+		s += ";" + p
+	}
+	s += ":" + value
+	for len(s) > icsLineLength {
+		// do not split a character
+		cut := icsLineLength
+		for !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, e.err = e.w.WriteString(s[:cut] + "\r\n"); e.err != nil {
+			return
+		}
+		s = " " + s[cut:]
+	}
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+func (e *icsWriter) task(t *TaskItem) {
+	event := t.Status == Open && !t.IsAllDay && !t.StartDate.IsZero() && t.DueDate.After(t.StartDate.Time)
+	component := "VTODO"
+	if event {
+		component = "VEVENT"
+	}
+	e.line("BEGIN", component)
+	uid := t.Id
+	if uid == "" {
+		uid = newObjectId()
+	}
+	e.line("UID", uid)
+	e.line("DTSTAMP", icsStamp(t).Format(ruleTimeLayout))
+	e.line("SUMMARY", icsTextEscaper.Replace(t.Title))
+	// the checklists keep their description in Desc
+	description := t.Content
+	if description == "" {
+		description = t.Desc
+	}
+	if description != "" {
+		e.line("DESCRIPTION", icsTextEscaper.Replace(description))
+	}
+
+	switch {
+	case event:
+		e.date("DTSTART", t, t.StartDate)
+		e.date("DTEND", t, t.DueDate)
+	case t.DueDate.IsZero():
+		if !t.StartDate.IsZero() {
+			e.date("DTSTART", t, t.StartDate)
+		}
+	default:
+		if !t.StartDate.IsZero() && !t.StartDate.Equal(t.DueDate.Time) {
+			e.date("DTSTART", t, t.StartDate)
+		}
+		e.date("DUE", t, t.DueDate)
+	}
+	if t.Repeat != "" {
+		e.repeat(t)
+	}
+
+	if p, ok := icsPriorities[t.Priority]; ok {
+		e.line("PRIORITY", strconv.Itoa(p))
+	}
+	if len(t.Tags) > 0 {
+		tags := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			tags[i] = icsTextEscaper.Replace(tag)
+		}
+		e.line("CATEGORIES", strings.Join(tags, ","))
+	}
+	if t.ParentId != "" {
+		e.line("RELATED-TO", t.ParentId, "RELTYPE=PARENT")
+	}
+	switch t.Status {
+	case Completed:
+		e.line("STATUS", "COMPLETED")
+		if !t.CompletedTime.IsZero() {
+			e.line("COMPLETED", t.CompletedTime.UTC().Format(ruleTimeLayout))
+		}
+	case Abandoned:
+		e.line("STATUS", "CANCELLED")
+	default:
+		if !event {
+			e.line("STATUS", "NEEDS-ACTION")
+		}
+	}
+
+	// the components come after the properties
+	for _, r := range t.Reminders {
+		if !r.Parsed() {
+			continue
+		}
+		e.line("BEGIN", "VALARM")
+		e.line("ACTION", "DISPLAY")
+		e.line("DESCRIPTION", icsTextEscaper.Replace(t.Title))
+		switch {
+		case r.Absolute != nil:
+			e.line("TRIGGER", r.Absolute.UTC().Format(ruleTimeLayout), "VALUE=DATE-TIME")
+		case !event && !t.DueDate.IsZero():
+			e.line("TRIGGER", formatTriggerDuration(r.Trigger), "RELATED=END")
+		default:
+			e.line("TRIGGER", formatTriggerDuration(r.Trigger))
+		}
+		e.line("END", "VALARM")
+	}
+	e.line("END", component)
+}
+
+// a DTSTART, DUE or DTEND of the task
+func (e *icsWriter) date(name string, t *TaskItem, date TickTickTime) {
+	loc := t.Location()
+	switch {
+	case t.IsAllDay:
+		// the tasks without time zone were dated in time.Local, see SetDue
+		if loc == nil {
+			loc = time.Local
+		}
+		e.line(name, t.dateIn(date, loc).Format(ruleDateLayout), "VALUE=DATE")
+	case loc != nil && loc != time.UTC:
+		e.line(name, date.In(loc).Format(ruleLocalLayout), "TZID="+loc.String())
+	default:
+		e.line(name, date.UTC().Format(ruleTimeLayout))
+	}
+}
+
+func (e *icsWriter) repeat(t *TaskItem) {
+	exact := false
+	if rule, err := t.RepeatRule(); err == nil {
+		standard := *rule
+		standard.Skip, standard.Lunar, standard.unknown = nil, false, nil
+		if standard.Workday != 0 && len(standard.ByDay) == 0 && len(standard.BySetPos) == 0 {
+			for d := time.Monday; d <= time.Friday; d++ {
+				standard.ByDay = append(standard.ByDay, RRuleDay{Weekday: d})
+			}
+			standard.BySetPos = []int{standard.Workday}
+		}
+		standard.Workday = 0
+		if len(standard.Dates) > 0 {
+			dates := make([]string, len(standard.Dates))
+			for i, d := range standard.Dates {
+				dates[i] = d.Format(ruleDateLayout)
+			}
+			e.line("RDATE", strings.Join(dates, ","), "VALUE=DATE")
+		} else if !rule.Lunar {
+			// the lunar dates of RSCALE are not understood by most calendars
+			e.line("RRULE", strings.TrimPrefix(standard.String(), rrulePrefix))
+		}
+		exact = standard.String() == rule.String()
+	}
+	if !exact {
+		e.line(icsRepeatProperty, icsTextEscaper.Replace(t.Repeat))
+	}
+	if t.RepeatFrom == RepeatFromCompletedTime {
+		e.line(icsRepeatFromProperty, string(t.RepeatFrom))
+	}
+}
+
+// the time zone of the tasks that have one, if they all have the same
+func icsCommonZone(tasks []TaskItem) string {
+	zone := ""
+	for i := range tasks {
+		if loc := tasks[i].Location(); loc != nil {
+			if zone != "" && zone != loc.String() {
+				return ""
+			}
+			zone = loc.String()
+		}
+	}
+	return zone
+}
+
+// the last modification of the task, else now
+func icsStamp(t *TaskItem) time.Time {
+	var modified TickTickTime
+	if raw, ok := t.unknownFields["modifiedTime"]; ok && json.Unmarshal(raw, &modified) == nil && !modified.IsZero() {
+		return modified.UTC()
+	}
+	return time.Now().UTC()
+}
+
+// ImportICS reads the VTODO and VEVENT components of the calendars in r as tasks ready for
+// CreateTask, the reverse of ExportICS:
+//
+//   - the tasks have no id, no project and no parent, UID and RELATED-TO are dropped,
+//     see ImportICSTasks to keep them
+//   - DTSTART and DUE (DTEND or DURATION for the events, where the end date is exclusive)
+//     are the start and the due dates, the tasks with dates only are all day
+//   - X-TICKTICK-REPEAT, else RRULE, else RDATE is Repeat
+//   - the VALARM triggers are the reminders, relative to DTSTART for the events and to DUE
+//     for the to-dos like in ExportICS, the to-do triggers RELATED to DTSTART (the default)
+//     are moved to DUE
+//   - PRIORITY 1 to 4 is high, 5 medium and 6 to 9 low
+//   - CATEGORIES are the tags, without the duplicates in another case
+//   - STATUS and COMPLETED give the status
+//
+// The modified occurrences of the repeating events (with RECURRENCE-ID) are left out.
+// A TZID is an IANA name, or the X-LIC-LOCATION of its VTIMEZONE, else the last standard offset
+// of its VTIMEZONE. The floating times and the dates are in X-WR-TIMEZONE, else in time.Local,
+// the UTC times and the times of the standard offsets are moved to it.
+// Data that can not be parsed returns ErrInvalidICS.
+func ImportICS(r io.Reader) ([]TaskItem, error) {
+	imported, err := ImportICSTasks(r)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []TaskItem
+	for _, t := range imported {
+		tasks = append(tasks, t.Task)
+	}
+	return tasks, nil
+}
+
+// ICSTask is a task read by ImportICSTasks with the uids of its component, they are not task ids
+type ICSTask struct {
+	Task      TaskItem
+	UID       string
+	ParentUID string // of the parent RELATED-TO, the parent may not be in the data
+}
+
+// ImportICSTasks is ImportICS keeping the uids, which tell the subtasks: create the tasks, then
+// make a subtask of each task whose ParentUID is the UID of another task with MakeSubtask.
+func ImportICSTasks(r io.Reader) ([]ICSTask, error) {
+	calendars, err := readICS(r)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []ICSTask
+	for _, cal := range calendars {
+		z := newICSZones(cal)
+		for _, c := range cal.components {
+			if c.name != "VTODO" && c.name != "VEVENT" {
+				continue
+			}
+			if _, ok := c.get("RECURRENCE-ID"); ok {
+				continue
+			}
+			t, err := z.task(c)
+			if err != nil {
+				return nil, err
+			}
+			imported := ICSTask{Task: t}
+			if p, ok := c.get("UID"); ok {
+				imported.UID = p.value
+			}
+			for _, p := range c.all("RELATED-TO") {
+				if reltype := strings.ToUpper(p.params["RELTYPE"]); reltype == "" || reltype == "PARENT" {
+					imported.ParentUID = p.value
+					break
+				}
+			}
+			tasks = append(tasks, imported)
+		}
+	}
+	return tasks, nil
+}
+
+// icsProperty is a content line, the names are in upper case
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+	line   int // in the data, for the errors
+}
+
+func (p icsProperty) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: line %d: %v %v", ErrInvalidICS, p.line, p.name, fmt.Errorf(format, args...))
+}
+
+type icsComponent struct {
+	name       string
+	props      []icsProperty
+	components []*icsComponent
+}
+
+// the first property with the name
+func (c *icsComponent) get(name string) (icsProperty, bool) {
+	for _, p := range c.props {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return icsProperty{}, false
+}
+
+func (c *icsComponent) all(name string) []icsProperty {
+	var props []icsProperty
+	for _, p := range c.props {
+		if p.name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// read the VCALENDAR components of r
+func readICS(r io.Reader) ([]*icsComponent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var lines []string
+	var numbers []int
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		// unfold the continuation lines
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines, numbers = append(lines, line), append(numbers, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var calendars, stack []*icsComponent
+	for i, line := range lines {
+		p, err := parseICSLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidICS, numbers[i], err)
+		}
+		p.line = numbers[i]
+		switch p.name {
+		case "BEGIN":
+			c := &icsComponent{name: strings.ToUpper(p.value)}
+			if len(stack) == 0 {
+				if c.name != "VCALENDAR" {
+					return nil, p.errorf("%v is not in a VCALENDAR", c.name)
+				}
+				calendars = append(calendars, c)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.components = append(parent.components, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(p.value) {
+				return nil, p.errorf("%v does not end the open component", p.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, p.errorf("is not in a VCALENDAR")
+			}
+			c := stack[len(stack)-1]
+			c.props = append(c.props, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: %v does not end", ErrInvalidICS, stack[len(stack)-1].name)
+	}
+	if len(calendars) == 0 {
+		return nil, fmt.Errorf("%w: no VCALENDAR", ErrInvalidICS)
+	}
+	return calendars, nil
+}
+
+// parse a content line, name *(";" param "=" value) ":" value, the param values may be quoted
+func parseICSLine(line string) (icsProperty, error) {
+	p := icsProperty{params: map[string]string{}}
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return p, fmt.Errorf("%q is not a property", line)
+	}
+	p.name = strings.ToUpper(line[:end])
+	rest := line[end:]
+	for strings.HasPrefix(rest, ";") {
+		name, value, ok := strings.Cut(rest[1:], "=")
+		if !ok || name == "" {
+			return p, fmt.Errorf("%v has an invalid param", p.name)
+		}
+		if quoted, ok := strings.CutPrefix(value, `"`); ok {
+			end := strings.IndexByte(quoted, '"')
+			if end < 0 {
+				return p, fmt.Errorf("%v has an unterminated quote", p.name)
+			}
+			value, rest = quoted[:end], quoted[end+1:]
+		} else {
+			end := strings.IndexAny(value, ";:")
+			if end < 0 {
+				return p, fmt.Errorf("%v has no value", p.name)
+			}
+			value, rest = value[:end], value[end:]
+		}
+		p.params[strings.ToUpper(name)] = value
+	}
+	value, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return p, fmt.Errorf("%v has no value", p.name)
+	}
+	p.value = value
+	return p, nil
+}
+
+func unescapeICSText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' || s[i] == 'N' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// split a list of texts on the commas that are not escaped, and unescape them
+func splitICSText(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescapeICSText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, unescapeICSText(s[start:]))
+}
+
+// icsZones resolves the times of a calendar
+type icsZones struct {
+	cal      *icsComponent
+	floating *time.Location // of the floating times and the dates
+}
+
+func newICSZones(cal *icsComponent) icsZones {
+	z := icsZones{cal: cal, floating: time.Local}
+	if p, ok := cal.get("X-WR-TIMEZONE"); ok {
+		if loc, err := time.LoadLocation(p.value); err == nil && p.value != "" {
+			z.floating = loc
+		}
+	}
+	return z
+}
+
+// the location of a TZID, and whether it is an IANA time zone that TimeZone can name
+func (z icsZones) location(tzid string) (*time.Location, bool, error) {
+	if loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+		return loc, true, nil
+	}
+	for _, c := range z.cal.components {
+		if id, _ := c.get("TZID"); c.name != "VTIMEZONE" || id.value != tzid {
+			continue
+		}
+		if p, ok := c.get("X-LIC-LOCATION"); ok {
+			if loc, err := time.LoadLocation(p.value); err == nil {
+				return loc, true, nil
+			}
+		}
+		var offset *icsProperty
+		for _, sub := range c.components {
+			if p, ok := sub.get("TZOFFSETTO"); ok && sub.name == "STANDARD" {
+				offset = &p
+			}
+		}
+		if offset != nil {
+			seconds, err := parseUTCOffset(offset.value)
+			if err != nil {
+				return nil, false, offset.errorf("%v", err)
+			}
+			return time.FixedZone(tzid, seconds), false, nil
+		}
+	}
+	return nil, false, fmt.Errorf("unknown time zone %q", tzid)
+}
+
+// an offset like +0800 or -053000, in seconds
+func parseUTCOffset(s string) (int, error) {
+	if (len(s) != 5 && len(s) != 7) || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+	seconds := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if 1+2*i >= len(s) {
+			break
+		}
+		n, err := strconv.Atoi(s[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		seconds += n * unit
+	}
+	if s[0] == '-' {
+		seconds = -seconds
+	}
+	return seconds, nil
+}
+
+// the time of a DATE or DATE-TIME property, and whether it is a date
+func (z icsZones) time(p icsProperty) (time.Time, bool, error) {
+	loc, named := z.floating, true
+	if tzid := p.params["TZID"]; tzid != "" {
+		var err error
+		if loc, named, err = z.location(tzid); err != nil {
+			return time.Time{}, false, p.errorf("%v", err)
+		}
+	}
+	var t time.Time
+	var err error
+	isDate := strings.EqualFold(p.params["VALUE"], "DATE") || len(p.value) == len(ruleDateLayout)
+	switch {
+	case isDate:
+		t, err = time.ParseInLocation(ruleDateLayout, p.value, loc)
+	case strings.HasSuffix(p.value, "Z"):
+		t, err = time.Parse(ruleTimeLayout, p.value)
+		named = false
+	default:
+		t, err = time.ParseInLocation(ruleLocalLayout, p.value, loc)
+	}
+	if err != nil {
+		return time.Time{}, false, p.errorf("invalid time %q", p.value)
+	}
+	if !named && !isDate {
+		// shown in the time zone of the calendar
+		t = t.In(z.floating)
+	}
+	return t, isDate, nil
+}
+
+// the time of the property of c, the zero time if it has none
+func (z icsZones) optionalTime(c *icsComponent, name string) (time.Time, bool, error) {
+	p, ok := c.get(name)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	return z.time(p)
+}
+
+func (z icsZones) task(c *icsComponent) (TaskItem, error) {
+	var t TaskItem
+	if p, ok := c.get("SUMMARY"); ok {
+		t.Title = unescapeICSText(p.value)
+	}
+	if p, ok := c.get("DESCRIPTION"); ok {
+		t.Content = unescapeICSText(p.value)
+	}
+
+	start, startIsDate, err := z.optionalTime(c, "DTSTART")
+	if err != nil {
+		return t, err
+	}
+	endName := "DUE"
+	if c.name == "VEVENT" {
+		endName = "DTEND"
+	}
+	due, dueIsDate, err := z.optionalTime(c, endName)
+	if err != nil {
+		return t, err
+	}
+	if due.IsZero() && !start.IsZero() {
+		if p, ok := c.get("DURATION"); ok {
+			d, err := parseTriggerDuration(p.value)
+			if err != nil {
+				return t, p.errorf("invalid duration %q", p.value)
+			}
+			due, dueIsDate = start.Add(d), startIsDate
+		} else if c.name == "VEVENT" {
+			due, dueIsDate = start, startIsDate
+		}
+	}
+	allDay := (start.IsZero() || startIsDate) && (due.IsZero() || dueIsDate)
+	if c.name == "VEVENT" && allDay && due.After(start) {
+		due = due.AddDate(0, 0, -1)
+	}
+	if !start.IsZero() {
+		t.SetStart(start, allDay)
+	}
+	if !due.IsZero() {
+		t.SetDue(due, allDay)
+	}
+
+	if err := z.repeat(&t, c); err != nil {
+		return t, err
+	}
+	// the reminders of the to-dos are relative to the due date
+	var fromStart time.Duration
+	if c.name == "VTODO" && !start.IsZero() && !due.IsZero() {
+		fromStart = start.Sub(due)
+	}
+	if err := z.reminders(&t, c, fromStart); err != nil {
+		return t, err
+	}
+	if p, ok := c.get("PRIORITY"); ok {
+		n, err := strconv.Atoi(p.value)
+		if err != nil || n < 0 || n > 9 {
+			return t, p.errorf("invalid priority %q", p.value)
+		}
+		switch {
+		case n >= 1 && n <= 4:
+			t.Priority = 5
+		case n == 5:
+			t.Priority = 3
+		case n >= 6:
+			t.Priority = 1
+		}
+	}
+	for _, p := range c.all("CATEGORIES") {
+		for _, tag := range splitICSText(p.value) {
+			if tag = strings.TrimSpace(tag); tag != "" && !slices.ContainsFunc(t.Tags, func(other string) bool {
+				return strings.EqualFold(other, tag)
+			}) {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	}
+	if p, ok := c.get("COMPLETED"); ok {
+		completed, _, err := z.time(p)
+		if err != nil {
+			return t, err
+		}
+		t.CompletedTime = NewTickTickTime(completed)
+		t.Status = Completed
+	}
+	if p, ok := c.get("STATUS"); ok {
+		switch strings.ToUpper(p.value) {
+		case "COMPLETED":
+			t.Status = Completed
+		case "CANCELLED":
+			t.Status = Abandoned
+		default:
+			t.Status = Open
+		}
+	}
+	return t, nil
+}
+
+func (z icsZones) repeat(t *TaskItem, c *icsComponent) error {
+	from := RepeatFromDueDate
+	if p, ok := c.get(icsRepeatFromProperty); ok && RepeatFrom(p.value) == RepeatFromCompletedTime {
+		from = RepeatFromCompletedTime
+	}
+	if p, ok := c.get(icsRepeatProperty); ok {
+		repeat := unescapeICSText(p.value)
+		if _, err := ParseRRule(repeat); err != nil {
+			return fmt.Errorf("%w: line %d: %w", ErrInvalidICS, p.line, err)
+		}
+		t.Repeat, t.RepeatFrom = repeat, from
+		return nil
+	}
+	if p, ok := c.get("RRULE"); ok {
+		rule, err := ParseRRule(p.value)
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %w", ErrInvalidICS, p.line, err)
+		}
+		t.SetRepeat(rule, from)
+		return nil
+	}
+	// the custom dates
+	rule := &RRule{}
+	for _, p := range c.all("RDATE") {
+		for _, value := range strings.Split(p.value, ",") {
+			date, _, err := z.time(icsProperty{name: p.name, params: p.params, value: value, line: p.line})
+			if err != nil {
+				return err
+			}
+			rule.Dates = append(rule.Dates, time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC))
+		}
+	}
+	if len(rule.Dates) > 0 {
+		t.SetRepeat(rule, from)
+	}
+	return nil
+}
+
+// the relative triggers RELATED to the start of a to-do are moved by fromStart
+func (z icsZones) reminders(t *TaskItem, c *icsComponent, fromStart time.Duration) error {
+	for _, alarm := range c.components {
+		if alarm.name != "VALARM" {
+			continue
+		}
+		p, ok := alarm.get("TRIGGER")
+		if !ok {
+			return fmt.Errorf("%w: VALARM without TRIGGER in %q", ErrInvalidICS, t.Title)
+		}
+		var r Reminder
+		if strings.EqualFold(p.params["VALUE"], "DATE-TIME") {
+			at, _, err := z.time(p)
+			if err != nil {
+				return err
+			}
+			at = at.UTC()
+			r.Absolute = &at
+		} else {
+			d, err := parseTriggerDuration(p.value)
+			if err != nil {
+				return fmt.Errorf("%w: line %d: %w: %q", ErrInvalidICS, p.line, ErrInvalidReminder, p.value)
+			}
+			r.Trigger = d
+			if !strings.EqualFold(p.params["RELATED"], "END") {
+				r.Trigger += fromStart
+			}
+		}
+		duplicated := false
+		for _, other := range t.Reminders {
+			duplicated = duplicated || other.String() == r.String()
+		}
+		if !duplicated {
+			r.Id = newObjectId()
+			t.Reminders = append(t.Reminders, r)
+		}
+	}
+	return nil
+}
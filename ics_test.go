@@ -0,0 +1,256 @@
+package ticktick
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the tasks of testdata/ics/export.ics
+const icsExportTasks = `[
+	{"id": "63b7a0f0e4b0f1a2b3c4d5e1", "projectId": "pid1", "title": "Weekly report, draft; v2", "content": "line 1\nline 2",
+	 "isAllDay": true, "startDate": "2023-01-05T16:00:00.000+0000", "dueDate": "2023-01-05T16:00:00.000+0000", "timeZone": "Asia/Shanghai",
+	 "repeat": "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=FR", "repeatFrom": "0", "priority": 5, "tags": ["work", "report"],
+	 "reminders": [{"id": "r1", "trigger": "TRIGGER:P0DT9H0M0S"}], "status": 0, "modifiedTime": "2023-01-04T02:00:00.000+0000"},
+	{"id": "63b7a0f0e4b0f1a2b3c4d5e2", "projectId": "pid1", "parentId": "63b7a0f0e4b0f1a2b3c4d5e1",
+	 "title": "和设计团队一起评审新版本的交互稿，并整理出下周迭代需要修改的问题清单",
+	 "startDate": "2023-01-06T01:00:00.000+0000", "dueDate": "2023-01-06T02:30:00.000+0000", "timeZone": "Asia/Shanghai",
+	 "repeat": "RRULE:FREQ=DAILY;INTERVAL=1;TT_SKIP=WEEKEND", "repeatFrom": "0", "priority": 3,
+	 "reminders": [{"id": "r2", "trigger": "TRIGGER:-PT15M"}], "status": 0, "modifiedTime": "2023-01-04T02:00:00.000+0000"},
+	{"id": "63b7a0f0e4b0f1a2b3c4d5e3", "projectId": "pid2", "title": "Pay the rent",
+	 "startDate": "2023-01-31T08:00:00.000+0000", "dueDate": "2023-01-31T08:00:00.000+0000",
+	 "repeat": "RRULE:FREQ=MONTHLY;INTERVAL=1;TT_WORKDAY=-1", "repeatFrom": "1", "priority": 1,
+	 "reminders": [{"id": "r3", "trigger": "TRIGGER;VALUE=DATE-TIME:20230130T090000Z"}],
+	 "status": 2, "completedTime": "2023-01-30T10:12:00.000+0000", "modifiedTime": "2023-01-30T10:12:00.000+0000"},
+	{"id": "63b7a0f0e4b0f1a2b3c4d5e4", "projectId": "pid2", "title": "Packing list", "kind": "CHECKLIST", "desc": "for the trip",
+	 "repeat": "ERULE:NAME=CUSTOM;BYDATE=20230110,20230120", "repeatFrom": "0", "items": [{"id": "i1", "title": "passport", "status": 0}],
+	 "status": -1, "modifiedTime": "2023-01-04T02:00:00.000+0000"}
+]`
+
+func TestExportICS(t *testing.T) {
+	assert := assert.New(t)
+	var tasks []TaskItem
+	assert.Nil(json.Unmarshal([]byte(icsExportTasks), &tasks))
+
+	// normal case
+	var buf bytes.Buffer
+	assert.Nil(ExportICS(&buf, tasks))
+	out := buf.String()
+	expected, err := os.ReadFile("testdata/ics/export.ics")
+	assert.Nil(err)
+	assert.Equal(string(expected), strings.ReplaceAll(out, "\r\n", "\n"))
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		assert.LessOrEqual(len(strings.TrimSuffix(line, "\r\n")), 75)
+	}
+}
+
+// a task as imported, the timed dates in RFC 3339, the all day ones as dates
+type icsTask struct {
+	title     string
+	content   string
+	start     string
+	due       string
+	timeZone  string
+	repeat    string
+	reminders []string
+	priority  int64
+	tags      []string
+	parentId  string
+	status    int64
+}
+
+func icsTaskOf(t TaskItem) icsTask {
+	format := func(date TickTickTime) string {
+		switch {
+		case date.IsZero():
+			return ""
+		case t.IsAllDay:
+			return t.dateIn(date, time.Local).Format("2006-01-02")
+		}
+		loc := t.Location()
+		if loc == nil {
+			loc = time.UTC
+		}
+		return date.In(loc).Format(time.RFC3339)
+	}
+	var reminders []string
+	for _, r := range t.Reminders {
+		reminders = append(reminders, r.String())
+	}
+	return icsTask{
+		title:     t.Title,
+		content:   t.Content,
+		start:     format(t.StartDate),
+		due:       format(t.DueDate),
+		timeZone:  t.TimeZone,
+		repeat:    t.Repeat,
+		reminders: reminders,
+		priority:  t.Priority,
+		tags:      t.Tags,
+		parentId:  t.ParentId,
+		status:    t.Status,
+	}
+}
+
+func TestImportICS(t *testing.T) {
+	assert := assert.New(t)
+	testCases := []struct {
+		file  string
+		tasks []icsTask
+	}{
+		{file: "google_calendar.ics", tasks: []icsTask{
+			{title: "Team standup", content: "Agenda: blockers, demos and the release plan.\nNotes in the shared doc: https://example.com/notes",
+				start: "2023-01-09T10:00:00-08:00", due: "2023-01-09T10:30:00-08:00", timeZone: "America/Los_Angeles",
+				repeat:    "RRULE:FREQ=WEEKLY;INTERVAL=1;UNTIL=20230331T065959Z;BYDAY=MO,WE;WKST=SU",
+				reminders: []string{"TRIGGER:-PT10M"}},
+			{title: "Offsite", start: "2023-01-20", due: "2023-01-22", timeZone: "America/Los_Angeles"},
+			{title: "Anniversary", start: "2023-02-14", due: "2023-02-14", timeZone: "America/Los_Angeles",
+				repeat: "RRULE:FREQ=YEARLY;INTERVAL=1", reminders: []string{"TRIGGER:-P1DT15H"}},
+			{title: "Vendor call", start: "2023-01-05T14:00:00-08:00", due: "2023-01-05T14:45:00-08:00", timeZone: "America/Los_Angeles",
+				status: Abandoned},
+		}},
+		{file: "apple_reminders.ics", tasks: []icsTask{
+			{title: "Renew passport", start: "2023-02-15T18:00:00+01:00", due: "2023-02-15T18:00:00+01:00", timeZone: "Europe/Paris",
+				reminders: []string{"TRIGGER;VALUE=DATE-TIME:20230215T090000Z", "TRIGGER:-PT1H"}, priority: 5, tags: []string{"Admin", "Travel"}},
+			{title: "Photos for the application", priority: 3, status: Completed},
+			{title: "Call the embassy", start: "2023-02-01", due: "2023-02-01", priority: 1},
+		}},
+		{file: "thunderbird_outlook.ics", tasks: []icsTask{
+			{title: "Water the plants", start: "2023-01-07T08:00:00+01:00", due: "2023-01-07T08:30:00+01:00", timeZone: "Europe/Berlin",
+				repeat: "RRULE:FREQ=DAILY;INTERVAL=2;COUNT=5", priority: 5, tags: []string{"home, garden", "chores"}},
+			{title: "Dentist follow-ups", start: "2023-03-01", repeat: "ERULE:NAME=CUSTOM;BYDATE=20230301,20230601,20230901"},
+			{title: "Quarterly review", start: "2023-01-12T09:00:00Z", due: "2023-01-12T10:00:00Z",
+				reminders: []string{"TRIGGER:-PT15M"}, priority: 3},
+		}},
+	}
+	for _, tc := range testCases {
+		f, err := os.Open(filepath.Join("testdata/ics", tc.file))
+		if !assert.Nil(err) {
+			continue
+		}
+		tasks, err := ImportICS(f)
+		f.Close()
+		if !assert.Nil(err, tc.file) {
+			continue
+		}
+		var got []icsTask
+		for _, task := range tasks {
+			// ready to create
+			assert.Empty(task.Id, tc.file)
+			assert.Empty(task.ProjectId, tc.file)
+			for _, r := range task.Reminders {
+				assert.Len(r.Id, 24, tc.file)
+			}
+			got = append(got, icsTaskOf(task))
+		}
+		assert.Equal(tc.tasks, got, tc.file)
+	}
+
+	// the completed time, and the uids telling the subtasks
+	f, _ := os.Open("testdata/ics/apple_reminders.ics")
+	defer f.Close()
+	tasks, err := ImportICSTasks(f)
+	assert.Nil(err)
+	if assert.Len(tasks, 3) {
+		assert.Equal("2023-01-10T12:00:00.000+0000", tasks[1].Task.CompletedTime.String())
+		assert.Equal("B1A4C2E0-7F3D-4E8A-9C61-2D5F0A3B7E11", tasks[0].UID)
+		assert.Equal(tasks[0].UID, tasks[1].ParentUID)
+		assert.Empty(tasks[1].Task.ParentId)
+		// a sibling is not a parent
+		assert.Equal("D0E1F2A3-B4C5-4D6E-8F70-819203A4B5C6", tasks[2].UID)
+		assert.Empty(tasks[2].ParentUID)
+	}
+
+	// the triggers of the to-dos are moved to the due date
+	alarmCases := []struct {
+		component string
+		trigger   string
+		reminder  string
+	}{
+		{component: "VTODO", trigger: "TRIGGER;RELATED=START:-PT15M", reminder: "TRIGGER:-PT1H15M"},
+		{component: "VTODO", trigger: "TRIGGER:PT0S", reminder: "TRIGGER:-PT1H"},
+		{component: "VTODO", trigger: "TRIGGER;RELATED=END:-PT15M", reminder: "TRIGGER:-PT15M"},
+		{component: "VEVENT", trigger: "TRIGGER:-PT15M", reminder: "TRIGGER:-PT15M"},
+	}
+	for _, tc := range alarmCases {
+		end := "DUE"
+		if tc.component == "VEVENT" {
+			end = "DTEND"
+		}
+		data := "BEGIN:VCALENDAR\nBEGIN:" + tc.component + "\nSUMMARY:call\nDTSTART:20230105T090000Z\n" + end +
+			":20230105T100000Z\nBEGIN:VALARM\nACTION:DISPLAY\n" + tc.trigger + "\nEND:VALARM\nEND:" + tc.component + "\nEND:VCALENDAR\n"
+		tasks, err := ImportICS(strings.NewReader(data))
+		if assert.Nil(err, tc.trigger) && assert.Len(tasks, 1, tc.trigger) && assert.Len(tasks[0].Reminders, 1, tc.trigger) {
+			assert.Equal(tc.reminder, tasks[0].Reminders[0].String(), tc.component+" "+tc.trigger)
+		}
+	}
+}
+
+func TestImportICSInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	files, err := filepath.Glob("testdata/ics/invalid/*.ics")
+	assert.Nil(err)
+	assert.NotEmpty(files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		assert.Nil(err)
+		tasks, err := ImportICS(bytes.NewReader(data))
+		assert.Nil(tasks, file)
+		assert.True(errors.Is(err, ErrInvalidICS), file, err)
+	}
+
+	// the errors tell where
+	_, err = ImportICS(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VTODO\nDUE:2023-01-05\nEND:VTODO\nEND:VCALENDAR\n"))
+	assert.ErrorContains(err, "line 3")
+	_, err = ImportICS(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VTODO\nRRULE:FREQ=HOURLY\nEND:VTODO\nEND:VCALENDAR\n"))
+	assert.True(errors.Is(err, ErrInvalidRepeat))
+	_, err = ImportICS(strings.NewReader(""))
+	assert.True(errors.Is(err, ErrInvalidICS))
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	var tasks []TaskItem
+	assert.Nil(json.Unmarshal([]byte(icsExportTasks), &tasks))
+
+	var buf bytes.Buffer
+	assert.Nil(ExportICS(&buf, tasks))
+	imported, err := ImportICSTasks(&buf)
+	assert.Nil(err)
+	if !assert.Len(imported, len(tasks)) {
+		return
+	}
+	for i, task := range tasks {
+		if task.TimeZone == "" && !task.DueDate.IsZero() {
+			// from X-WR-TIMEZONE
+			task.TimeZone = "Asia/Shanghai"
+		}
+		want := icsTaskOf(task)
+		if want.content == "" {
+			want.content = task.Desc
+		}
+		// the parent is a uid
+		want.parentId = ""
+		assert.Equal(want, icsTaskOf(imported[i].Task), task.Title)
+		assert.Equal(task.Id, imported[i].UID, task.Title)
+		assert.Equal(task.ParentId, imported[i].ParentUID, task.Title)
+		assert.Equal(task.RepeatFrom, imported[i].Task.RepeatFrom, task.Title)
+		assert.Equal(task.CompletedTime, imported[i].Task.CompletedTime, task.Title)
+		assert.Equal(task.StartDate, imported[i].Task.StartDate, task.Title)
+	}
+
+	// the tasks without id get one
+	buf.Reset()
+	assert.Nil(ExportICS(&buf, []TaskItem{{Title: "new"}}))
+	assert.Regexp(`\r\nUID:[0-9a-f]{24}\r\n`, buf.String())
+	assert.Regexp(`\r\nDTSTAMP:\d{8}T\d{6}Z\r\n`, buf.String())
+	assert.NotContains(buf.String(), "X-WR-TIMEZONE")
+}
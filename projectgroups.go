@@ -0,0 +1,198 @@
+package ticktick
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+const (
+	projectGroupBatchUrlEndpoint = "/batch/projectGroup" // POST
+
+	// the groupId that takes a project out of its group
+	noProjectGroupId = "NONE"
+)
+
+// ProjectGroup is a folder of projects in the sidebar
+type ProjectGroup struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	SortOrder int64  `json:"sortOrder"`
+}
+
+// ProjectTreeNode is an entry of the sidebar: either a group with its projects,
+// or a project outside of any group, in which case Group is nil
+type ProjectTreeNode struct {
+	Group    *ProjectGroup
+	Projects []Project
+}
+
+// replace or add the group in the local snapshot, or remove it and ungroup its projects if deleted
+func (c *Client) storeProjectGroup(g ProjectGroup, deleted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	groups := make([]ProjectGroup, 0, len(c.projectGroups)+1)
+	for _, old := range c.projectGroups {
+		if old.Id != g.Id {
+			groups = append(groups, old)
+		}
+	}
+	if !deleted {
+		groups = append(groups, g)
+	} else {
+		projects := make([]Project, len(c.projects))
+		for i, p := range c.projects {
+			if p.GroupId == g.Id {
+				p.GroupId = ""
+			}
+			projects[i] = p
+		}
+		c.projects = projects
+	}
+	c.projectGroups = groups
+}
+
+// CURD, Read, the synced project groups
+func (c *Client) ListProjectGroups() ([]ProjectGroup, error) {
+	return c.ListProjectGroupsCtx(context.Background())
+}
+
+// ListProjectGroupsCtx is ListProjectGroups with a context
+func (c *Client) ListProjectGroupsCtx(ctx context.Context) ([]ProjectGroup, error) {
+	if err := c.SyncCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]ProjectGroup(nil), c.projectGroups...), nil
+}
+
+// CURD, Create
+func (c *Client) CreateProjectGroup(name string) (*ProjectGroup, error) {
+	return c.CreateProjectGroupCtx(context.Background(), name)
+}
+
+// CreateProjectGroupCtx is CreateProjectGroup with a context
+func (c *Client) CreateProjectGroupCtx(ctx context.Context, name string) (*ProjectGroup, error) {
+	if name == "" {
+		return nil, fmt.Errorf("project group name is empty")
+	}
+	g := ProjectGroup{Id: newObjectId(), Name: name}
+	if err := c.batchItem(ctx, projectGroupBatchUrlEndpoint, "add", g, g.Id); err != nil {
+		return nil, err
+	}
+
+	c.storeProjectGroup(g, false)
+	return &g, nil
+}
+
+// CURD, Update
+func (c *Client) RenameProjectGroup(g *ProjectGroup, name string) (*ProjectGroup, error) {
+	return c.RenameProjectGroupCtx(context.Background(), g, name)
+}
+
+// RenameProjectGroupCtx is RenameProjectGroup with a context
+func (c *Client) RenameProjectGroupCtx(ctx context.Context, g *ProjectGroup, name string) (*ProjectGroup, error) {
+	if g.Id == "" {
+		return nil, fmt.Errorf("project group Id is empty")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("project group name is empty")
+	}
+	newg := *g
+	newg.Name = name
+	if err := c.batchItem(ctx, projectGroupBatchUrlEndpoint, "update", newg, newg.Id); err != nil {
+		return nil, err
+	}
+
+	c.storeProjectGroup(newg, false)
+	return &newg, nil
+}
+
+// CURD, Delete, the projects of the group are kept and ungrouped
+func (c *Client) DeleteProjectGroup(g *ProjectGroup) error {
+	return c.DeleteProjectGroupCtx(context.Background(), g)
+}
+
+// DeleteProjectGroupCtx is DeleteProjectGroup with a context
+func (c *Client) DeleteProjectGroupCtx(ctx context.Context, g *ProjectGroup) error {
+	if g.Id == "" {
+		return fmt.Errorf("project group Id is empty")
+	}
+	if err := c.batchItem(ctx, projectGroupBatchUrlEndpoint, "delete", g.Id, g.Id); err != nil {
+		return err
+	}
+
+	c.storeProjectGroup(*g, true)
+	return nil
+}
+
+// Move the project into the group, a nil group takes it out of its group
+func (c *Client) MoveProjectToGroup(p *Project, g *ProjectGroup) (*Project, error) {
+	return c.MoveProjectToGroupCtx(context.Background(), p, g)
+}
+
+// MoveProjectToGroupCtx is MoveProjectToGroup with a context
+func (c *Client) MoveProjectToGroupCtx(ctx context.Context, p *Project, g *ProjectGroup) (*Project, error) {
+	if p.Id == "" {
+		return nil, fmt.Errorf("%w: project Id is empty", ErrProjectNotFound)
+	}
+	newp := *p
+	item := *p
+	if g != nil {
+		if g.Id == "" {
+			return nil, fmt.Errorf("project group Id is empty")
+		}
+		newp.GroupId = g.Id
+		item.GroupId = g.Id
+	} else {
+		newp.GroupId = ""
+		item.GroupId = noProjectGroupId
+	}
+	if err := c.batchItem(ctx, projectBatchUrlEndpoint, "update", item, item.Id); err != nil {
+		return nil, err
+	}
+
+	c.storeProject(newp, false)
+	return &newp, nil
+}
+
+// the synced projects as shown in the sidebar, ordered by sortOrder. The archived projects are left out.
+func (c *Client) ProjectTree() []ProjectTreeNode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	type sortedNode struct {
+		node      ProjectTreeNode
+		sortOrder int64
+	}
+	var nodes []sortedNode
+	groupIndex := make(map[string]int)
+	for _, g := range c.projectGroups {
+		g := g
+		groupIndex[g.Id] = len(nodes)
+		nodes = append(nodes, sortedNode{node: ProjectTreeNode{Group: &g}, sortOrder: g.SortOrder})
+	}
+	for _, p := range c.projects {
+		if p.Closed {
+			continue
+		}
+		if i, ok := groupIndex[p.GroupId]; ok {
+			nodes[i].node.Projects = append(nodes[i].node.Projects, p)
+		} else {
+			nodes = append(nodes, sortedNode{node: ProjectTreeNode{Projects: []Project{p}}, sortOrder: p.SortOrder})
+		}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].sortOrder < nodes[j].sortOrder
+	})
+	tree := make([]ProjectTreeNode, len(nodes))
+	for i, n := range nodes {
+		sort.SliceStable(n.node.Projects, func(i, j int) bool {
+			return n.node.Projects[i].SortOrder < n.node.Projects[j].SortOrder
+		})
+		tree[i] = n.node
+	}
+	return tree
+}
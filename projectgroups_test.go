@@ -0,0 +1,204 @@
+package ticktick
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func BuildProjectGroupClient() *Client {
+	NewSignInTestServer()
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		JSON(map[string]any{
+			"inboxId": "testinboxid",
+			"projectGroups": []ProjectGroup{
+				{Id: "pgid1", Name: "pgname1", SortOrder: 20},
+				{Id: "pgid2", Name: "pgname2", SortOrder: 40},
+			},
+			"projectProfiles": []Project{
+				{Id: "pid1", Name: "pname1", GroupId: "pgid1", SortOrder: 2},
+				{Id: "pid2", Name: "pname2", GroupId: "pgid1", SortOrder: 1},
+				{Id: "pid3", Name: "pname3", SortOrder: 30},
+				{Id: "pid4", Name: "pname4", SortOrder: 10},
+				{Id: "pid5", Name: "pname5", GroupId: "pgid2", Closed: true},
+			},
+			"syncTaskBean": map[string]any{"update": []TaskItem{}},
+			"tags":         []TestTagItem{},
+		})
+	client, _ := NewClient("testuser", "testpass", "test")
+	return client
+}
+
+func TestProjectTree(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildProjectGroupClient()
+
+	tree := client.ProjectTree()
+	if assert.Len(tree, 4) {
+		assert.Nil(tree[0].Group)
+		assert.Equal("pname4", tree[0].Projects[0].Name)
+
+		assert.Equal("pgname1", tree[1].Group.Name)
+		if assert.Len(tree[1].Projects, 2) {
+			assert.Equal("pname2", tree[1].Projects[0].Name)
+			assert.Equal("pname1", tree[1].Projects[1].Name)
+		}
+
+		assert.Nil(tree[2].Group)
+		assert.Equal("pname3", tree[2].Projects[0].Name)
+
+		// the archived project is left out
+		assert.Equal("pgname2", tree[3].Group.Name)
+		assert.Empty(tree[3].Projects)
+	}
+}
+
+func TestListProjectGroups(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildProjectGroupClient()
+
+	// normal case
+	NewSyncTestServer(BuildSyncResponse())
+	groups, err := client.ListProjectGroups()
+	assert.Nil(err)
+	assert.Equal([]ProjectGroup{{Id: "pgid1", Name: "pgname1"}, {Id: "pgid2", Name: "pgname2"}}, groups)
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(404)
+	groups, err = client.ListProjectGroups()
+	assert.Nil(groups)
+	assert.NotNil(err)
+}
+
+func TestCreateProjectGroup(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildProjectGroupClient()
+
+	gock.New(baseUrlV2Test).
+		Post(projectGroupBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{}})
+
+	// normal case
+	g, err := client.CreateProjectGroup("pgname3")
+	assert.Nil(err)
+	assert.Equal("pgname3", g.Name)
+	assert.Len(g.Id, 24)
+	tree := client.ProjectTree()
+	assert.Equal("pgname3", tree[0].Group.Name)
+
+	// empty name
+	_, err = client.CreateProjectGroup("")
+	assert.NotNil(err)
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Post(projectGroupBatchUrlEndpoint).
+		Reply(500)
+	_, err = client.CreateProjectGroup("pgname4")
+	assert.NotNil(err)
+}
+
+func TestRenameProjectGroup(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildProjectGroupClient()
+
+	gock.New(baseUrlV2Test).
+		Post(projectGroupBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]any{"update": []ProjectGroup{{Id: "pgid1", Name: "renamed", SortOrder: 20}}}).
+		Reply(200).
+		JSON(map[string]any{})
+
+	// normal case
+	g, err := client.RenameProjectGroup(&ProjectGroup{Id: "pgid1", Name: "pgname1", SortOrder: 20}, "renamed")
+	assert.Nil(err)
+	assert.Equal("renamed", g.Name)
+	assert.Equal("renamed", client.ProjectTree()[1].Group.Name)
+
+	// no id or name
+	_, err = client.RenameProjectGroup(&ProjectGroup{}, "renamed")
+	assert.NotNil(err)
+	_, err = client.RenameProjectGroup(g, "")
+	assert.NotNil(err)
+}
+
+func TestDeleteProjectGroup(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildProjectGroupClient()
+
+	gock.New(baseUrlV2Test).
+		Post(projectGroupBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]any{"delete": []string{"pgid1"}}).
+		Reply(200).
+		JSON(map[string]any{})
+
+	// normal case, the projects are ungrouped
+	assert.Nil(client.DeleteProjectGroup(&ProjectGroup{Id: "pgid1"}))
+	tree := client.ProjectTree()
+	assert.Len(tree, 5)
+	p, _ := client.GetProject("pname1")
+	assert.Empty(p.GroupId)
+
+	// no id
+	assert.NotNil(client.DeleteProjectGroup(&ProjectGroup{}))
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Post(projectGroupBatchUrlEndpoint).
+		Reply(500)
+	assert.NotNil(client.DeleteProjectGroup(&ProjectGroup{Id: "pgid2"}))
+}
+
+func TestMoveProjectToGroup(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildProjectGroupClient()
+	p, _ := client.GetProject("pname3")
+
+	// into a group
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		MatchType("json").
+		JSON(map[string]any{"update": []Project{{Id: "pid3", Name: "pname3", GroupId: "pgid2", SortOrder: 30}}}).
+		Reply(200).
+		JSON(map[string]any{})
+	newp, err := client.MoveProjectToGroup(p, &ProjectGroup{Id: "pgid2"})
+	assert.Nil(err)
+	assert.Equal("pgid2", newp.GroupId)
+	tree := client.ProjectTree()
+	assert.Equal("pname3", tree[2].Projects[0].Name)
+
+	// out of the group
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		MatchType("json").
+		JSON(map[string]any{"update": []Project{{Id: "pid3", Name: "pname3", GroupId: noProjectGroupId, SortOrder: 30}}}).
+		Reply(200).
+		JSON(map[string]any{})
+	newp, err = client.MoveProjectToGroup(newp, nil)
+	assert.Nil(err)
+	assert.Empty(newp.GroupId)
+
+	// no id
+	_, err = client.MoveProjectToGroup(&Project{}, nil)
+	assert.NotNil(err)
+	_, err = client.MoveProjectToGroup(p, &ProjectGroup{})
+	assert.NotNil(err)
+}
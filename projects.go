@@ -0,0 +1,204 @@
+package ticktick
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/carlmjohnson/requests"
+)
+
+const (
+	projectBatchUrlEndpoint = "/batch/project"     // POST
+	projectListUrlEndpoint  = "/projects"          // GET
+	sectionListUrlEndpoint  = "/column/project/%v" // GET, with the project id
+)
+
+type Project struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Color     string `json:"color,omitempty"`
+	ViewMode  string `json:"viewMode,omitempty"` // list, kanban or timeline
+	Kind      string `json:"kind,omitempty"`     // TASK or NOTE
+	GroupId   string `json:"groupId,omitempty"`
+	Closed    bool   `json:"closed"` // archived
+	SortOrder int64  `json:"sortOrder"`
+}
+
+// Section is a column of a project, see TaskItem.ColumnId
+type Section struct {
+	Id        string `json:"id"`
+	ProjectId string `json:"projectId"`
+	Name      string `json:"name"`
+	SortOrder int64  `json:"sortOrder"`
+}
+
+// rebuild the project name and id maps from c.projects, c.mu must be held
+func (c *Client) indexProjects() {
+	c.projectName2Id = make(map[string]string)
+	c.id2ProjectName = make(map[string]string)
+	for _, p := range c.projects {
+		c.projectName2Id[p.Name] = p.Id
+		c.id2ProjectName[p.Id] = p.Name
+	}
+	c.projectName2Id["inbox"] = c.inboxId
+	c.id2ProjectName[c.inboxId] = "inbox"
+}
+
+// replace or add the project in the local snapshot, or remove it and its tasks if deleted
+func (c *Client) storeProject(p Project, deleted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	projects := make([]Project, 0, len(c.projects)+1)
+	for _, old := range c.projects {
+		if old.Id != p.Id {
+			projects = append(projects, old)
+		}
+	}
+	if !deleted {
+		projects = append(projects, p)
+	} else {
+		tasks := c.tasks[:0]
+		for _, t := range c.tasks {
+			if t.ProjectId != p.Id {
+				tasks = append(tasks, t)
+			}
+		}
+		c.tasks = tasks
+	}
+	c.projects = projects
+	c.indexProjects()
+}
+
+// CURD, Create. The id is generated if empty.
+func (c *Client) CreateProject(p *Project) (*Project, error) {
+	return c.CreateProjectCtx(context.Background(), p)
+}
+
+// CreateProjectCtx is CreateProject with a context
+func (c *Client) CreateProjectCtx(ctx context.Context, p *Project) (*Project, error) {
+	if p.Name == "" {
+		return nil, fmt.Errorf("project name is empty")
+	}
+	if _, ok := c.projectId(p.Name); ok {
+		return nil, fmt.Errorf("project name %v already exists", p.Name)
+	}
+	newp := *p
+	if newp.Id == "" {
+		newp.Id = newObjectId()
+	}
+	if err := c.batchItem(ctx, projectBatchUrlEndpoint, "add", newp, newp.Id); err != nil {
+		return nil, err
+	}
+
+	c.storeProject(newp, false)
+	return &newp, nil
+}
+
+// CURD, Update
+func (c *Client) UpdateProject(p *Project) (*Project, error) {
+	return c.UpdateProjectCtx(context.Background(), p)
+}
+
+// UpdateProjectCtx is UpdateProject with a context
+func (c *Client) UpdateProjectCtx(ctx context.Context, p *Project) (*Project, error) {
+	if p.Id == "" {
+		return nil, fmt.Errorf("%w: project Id is empty", ErrProjectNotFound)
+	}
+	newp := *p
+	if err := c.batchItem(ctx, projectBatchUrlEndpoint, "update", newp, newp.Id); err != nil {
+		return nil, err
+	}
+
+	c.storeProject(newp, false)
+	return &newp, nil
+}
+
+// Archive the project, the tasks are kept but hidden in the app
+func (c *Client) ArchiveProject(p *Project) (*Project, error) {
+	return c.ArchiveProjectCtx(context.Background(), p)
+}
+
+// ArchiveProjectCtx is ArchiveProject with a context
+func (c *Client) ArchiveProjectCtx(ctx context.Context, p *Project) (*Project, error) {
+	newp := *p
+	newp.Closed = true
+	return c.UpdateProjectCtx(ctx, &newp)
+}
+
+// CURD, Delete, the tasks of the project are deleted as well
+func (c *Client) DeleteProject(p *Project) error {
+	return c.DeleteProjectCtx(context.Background(), p)
+}
+
+// DeleteProjectCtx is DeleteProject with a context
+func (c *Client) DeleteProjectCtx(ctx context.Context, p *Project) error {
+	if p.Id == "" {
+		return fmt.Errorf("%w: project Id is empty", ErrProjectNotFound)
+	}
+	if err := c.batchItem(ctx, projectBatchUrlEndpoint, "delete", p.Id, p.Id); err != nil {
+		return err
+	}
+
+	c.storeProject(*p, true)
+	return nil
+}
+
+// CURD, Read, all the projects including the archived ones, the inbox is not a project.
+// They replace the synced projects.
+func (c *Client) ListProjects() ([]Project, error) {
+	return c.ListProjectsCtx(context.Background())
+}
+
+// ListProjectsCtx is ListProjects with a context
+func (c *Client) ListProjectsCtx(ctx context.Context) ([]Project, error) {
+	var resp []Project
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(projectListUrlEndpoint).
+			ToJSON(&resp)
+	}); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.projects = append([]Project(nil), resp...)
+	c.indexProjects()
+	for i := range c.tasks {
+		c.tasks[i].ProjectName = c.id2ProjectName[c.tasks[i].ProjectId]
+	}
+	return resp, nil
+}
+
+// find a synced project by name
+func (c *Client) GetProject(name string) (*Project, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, p := range c.projects {
+		if p.Name == name {
+			newp := p
+			return &newp, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrProjectNotFound, name)
+}
+
+// the sections of a project, they are not synced
+func (c *Client) ListSections(projectName string) ([]Section, error) {
+	return c.ListSectionsCtx(context.Background(), projectName)
+}
+
+// ListSectionsCtx is ListSections with a context
+func (c *Client) ListSectionsCtx(ctx context.Context, projectName string) ([]Section, error) {
+	projectId, ok := c.projectId(projectName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrProjectNotFound, projectName)
+	}
+	var resp []Section
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(fmt.Sprintf(sectionListUrlEndpoint, projectId)).
+			ToJSON(&resp)
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,211 @@
+package ticktick
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncProjects(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+
+	p, err := client.GetProject("pname2")
+	assert.Nil(err)
+	assert.Equal(&Project{Id: "pid2", Name: "pname2"}, p)
+
+	_, err = client.GetProject("pnameRandom")
+	assert.True(errors.Is(err, ErrProjectNotFound))
+}
+
+func TestCreateProject(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+
+	project := Project{Id: "pid3", Name: "pname3", Color: "#ff0000", ViewMode: "list", Kind: "TASK"}
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]any{"add": []Project{project}}).
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{"pid3": "etag"}, "id2error": map[string]string{}})
+
+	// normal case, the new project can be used right away
+	newp, err := client.CreateProject(&project)
+	assert.Nil(err)
+	assert.Equal(&project, newp)
+	task, err := NewTask(client, "test", "", time.Time{}, "pname3")
+	if assert.Nil(err) {
+		assert.Equal("pid3", task.ProjectId)
+	}
+
+	// the id is generated
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{}, "id2error": map[string]string{}})
+	newp, err = client.CreateProject(&Project{Name: "pname4"})
+	assert.Nil(err)
+	assert.Len(newp.Id, 24)
+
+	// the name is taken or empty
+	_, err = client.CreateProject(&Project{Name: "pname1"})
+	if assert.NotNil(err) {
+		assert.Contains(fmt.Sprint(err), "already exists")
+	}
+	_, err = client.CreateProject(&Project{})
+	assert.NotNil(err)
+
+	// the server refuses the project
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{}, "id2error": map[string]string{"pid5": "EXCEED_QUOTA"}})
+	_, err = client.CreateProject(&Project{Id: "pid5", Name: "pname5"})
+	var itemErr *ItemError
+	if assert.True(errors.As(err, &itemErr)) {
+		assert.Equal("pid5", itemErr.Id)
+		assert.Equal("EXCEED_QUOTA", itemErr.Message)
+	}
+	_, ok := client.projectId("pname5")
+	assert.False(ok)
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		Reply(500)
+	_, err = client.CreateProject(&Project{Name: "pname6"})
+	assert.NotNil(err)
+}
+
+func TestUpdateProject(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+
+	p, _ := client.GetProject("pname1")
+	p.Name = "pname1 renamed"
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]any{"update": []Project{*p}}).
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{"pid1": "etag"}})
+
+	// normal case, the name maps follow
+	newp, err := client.UpdateProject(p)
+	assert.Nil(err)
+	assert.Equal(p, newp)
+	_, ok := client.projectId("pname1")
+	assert.False(ok)
+	assert.Equal("pname1 renamed", client.projectName("pid1"))
+
+	// archive
+	archived := *newp
+	archived.Closed = true
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		MatchType("json").
+		JSON(map[string]any{"update": []Project{archived}}).
+		Reply(200).
+		JSON(map[string]any{})
+	newp, err = client.ArchiveProject(newp)
+	assert.Nil(err)
+	assert.True(newp.Closed)
+
+	// no id
+	_, err = client.UpdateProject(&Project{Name: "random"})
+	assert.True(errors.Is(err, ErrProjectNotFound))
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		Reply(404)
+	_, err = client.UpdateProject(p)
+	assert.NotNil(err)
+}
+
+func TestDeleteProject(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+
+	p, _ := client.GetProject("pname2")
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]any{"delete": []string{"pid2"}}).
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{}})
+
+	// normal case
+	assert.Nil(client.DeleteProject(p))
+	_, err := client.GetProject("pname2")
+	assert.True(errors.Is(err, ErrProjectNotFound))
+	_, err = NewTask(client, "test", "", time.Time{}, "pname2")
+	assert.True(errors.Is(err, ErrProjectNotFound))
+	// its tasks are gone without waiting for the next sync
+	tasks, _ := client.QueryTasks(Query())
+	assert.Equal([]string{"1", "2"}, ids(tasks))
+
+	// no id
+	err = client.DeleteProject(&Project{})
+	assert.True(errors.Is(err, ErrProjectNotFound))
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Post(projectBatchUrlEndpoint).
+		Reply(404)
+	assert.NotNil(client.DeleteProject(p))
+}
+
+func TestListProjects(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+
+	projects := []Project{
+		{Id: "pid1", Name: "pname1 renamed", Kind: "TASK"},
+		{Id: "pid2", Name: "pname2", Kind: "NOTE", Closed: true, GroupId: "pgid1"},
+		{Id: "pid3", Name: "pname3", Kind: "TASK"},
+	}
+	gock.New(baseUrlV2Test).
+		Get(projectListUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		JSON(projects)
+
+	// normal case
+	newProjects, err := client.ListProjects()
+	assert.Nil(err)
+	assert.Equal(projects, newProjects)
+
+	// the listed projects are the synced ones from now on
+	task, err := NewTask(client, "test", "", time.Time{}, "pname3")
+	if assert.Nil(err) {
+		assert.Equal("pid3", task.ProjectId)
+	}
+	_, err = client.GetProject("pname1")
+	assert.True(errors.Is(err, ErrProjectNotFound))
+	client.mu.RLock()
+	assert.Equal("pname1 renamed", client.tasks[0].ProjectName)
+	assert.Equal("inbox", client.id2ProjectName[client.inboxId])
+	client.mu.RUnlock()
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Get(projectListUrlEndpoint).
+		Reply(404)
+	newProjects, err = client.ListProjects()
+	assert.Nil(newProjects)
+	assert.NotNil(err)
+}
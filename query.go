@@ -0,0 +1,357 @@
+package ticktick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the task statuses
+const (
+	Abandoned int64 = -1 // won't do
+	Open      int64 = 0
+	Completed int64 = 2
+)
+
+// SortKey is an order of the query results, see TaskQuery.Sorted
+type SortKey int
+
+const (
+	ByDue       SortKey = iota // earliest due date first, the tasks without due date last
+	ByStart                    // earliest start date first, the tasks without start date last
+	ByPriority                 // highest priority first
+	ByTitle                    // alphabetical, ignoring case
+	BySortOrder                // the manual order of the app
+)
+
+// TaskQuery is a filter and an order of tasks, built by chaining the conditions from Query.
+// The conditions are ANDed, use Or and Not for the other compositions:
+//
+//	ticktick.Query().InProjects("Work").WithAnyTag("urgent").DueBefore(friday).Status(ticktick.Open).Sorted(ticktick.ByDue)
+//
+// Every method returns a new query and leaves its receiver as it was, so a base query can be
+// shared by several branches. Run it on the synced tasks with Client.QueryTasks, or on any tasks with Apply.
+type TaskQuery struct {
+	match  func(t *TaskItem) bool
+	sort   []SortKey
+	limit  int
+	closed bool // asks for the completed or abandoned tasks, which are not synced
+}
+
+// An empty query, it matches every task
+func Query() *TaskQuery {
+	return &TaskQuery{match: func(*TaskItem) bool { return true }}
+}
+
+// a copy of the query with another match
+func (q *TaskQuery) with(match func(t *TaskItem) bool) *TaskQuery {
+	return &TaskQuery{match: match, sort: append([]SortKey(nil), q.sort...), limit: q.limit, closed: q.closed}
+}
+
+// Add a condition to the query
+func (q *TaskQuery) Where(f func(t *TaskItem) bool) *TaskQuery {
+	match := q.match
+	return q.with(func(t *TaskItem) bool { return match(t) && f(t) })
+}
+
+// Match the tasks matching all the queries too
+func (q *TaskQuery) And(queries ...*TaskQuery) *TaskQuery {
+	matches := matchesOf(queries)
+	and := q.Where(func(t *TaskItem) bool {
+		for _, match := range matches {
+			if !match(t) {
+				return false
+			}
+		}
+		return true
+	})
+	and.closed = and.closed || closedOf(queries)
+	return and
+}
+
+// Match the tasks matching the query so far or any of the queries
+func (q *TaskQuery) Or(queries ...*TaskQuery) *TaskQuery {
+	match, matches := q.match, matchesOf(queries)
+	or := q.with(func(t *TaskItem) bool {
+		if match(t) {
+			return true
+		}
+		for _, other := range matches {
+			if other(t) {
+				return true
+			}
+		}
+		return false
+	})
+	or.closed = or.closed || closedOf(queries)
+	return or
+}
+
+// Leave out the tasks matching the query
+func (q *TaskQuery) Not(query *TaskQuery) *TaskQuery {
+	match := query.match
+	return q.Where(func(t *TaskItem) bool { return !match(t) })
+}
+
+// the conditions of the queries as they are now
+func matchesOf(queries []*TaskQuery) []func(t *TaskItem) bool {
+	matches := make([]func(t *TaskItem) bool, len(queries))
+	for i, other := range queries {
+		matches[i] = other.match
+	}
+	return matches
+}
+
+func closedOf(queries []*TaskQuery) bool {
+	for _, other := range queries {
+		if other.closed {
+			return true
+		}
+	}
+	return false
+}
+
+// The tasks with one of the ids
+func (q *TaskQuery) WithIds(ids ...string) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return Contains(ids, t.Id) })
+}
+
+// The tasks with the text in their title, ignoring case
+func (q *TaskQuery) TitleContains(text string) *TaskQuery {
+	text = strings.ToLower(text)
+	return q.Where(func(t *TaskItem) bool { return strings.Contains(strings.ToLower(t.Title), text) })
+}
+
+// The tasks in one of the projects, given by name or id
+func (q *TaskQuery) InProjects(projects ...string) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool {
+		return Contains(projects, t.ProjectId) || (t.ProjectName != "" && Contains(projects, t.ProjectName))
+	})
+}
+
+// The tasks with at least one of the tags, ignoring case
+func (q *TaskQuery) WithAnyTag(tags ...string) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool {
+		for _, tag := range tags {
+			if hasTag(t, tag) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// The tasks with all the tags, ignoring case
+func (q *TaskQuery) WithAllTags(tags ...string) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool {
+		for _, tag := range tags {
+			if !hasTag(t, tag) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// The tasks without tag
+func (q *TaskQuery) Untagged() *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return len(t.Tags) == 0 })
+}
+
+func hasTag(t *TaskItem, tag string) bool {
+	for _, v := range t.Tags {
+		if tagName(v) == tagName(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// The tasks with one of the statuses, Open, Completed or Abandoned. Only the open tasks are
+// synced, so QueryTasks returns ErrClosedTasksNotSynced for the other statuses: list them with
+// ListCompletedTasks and ListAbandonedTasks, and filter those with Apply.
+func (q *TaskQuery) Status(statuses ...int64) *TaskQuery {
+	status := q.Where(func(t *TaskItem) bool { return Contains(statuses, t.Status) })
+	status.closed = status.closed || slices.ContainsFunc(statuses, func(s int64) bool { return s != Open })
+	return status
+}
+
+// The tasks with one of the priorities, 0 none, 1 low, 3 medium and 5 high
+func (q *TaskQuery) Priority(priorities ...int64) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return Contains(priorities, t.Priority) })
+}
+
+// The tasks of one of the kinds, TEXT, NOTE or CHECKLIST
+func (q *TaskQuery) Kind(kinds ...string) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return Contains(kinds, t.Kind) })
+}
+
+// The subtasks of one of the tasks
+func (q *TaskQuery) ChildOf(parentIds ...string) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return t.ParentId != "" && Contains(parentIds, t.ParentId) })
+}
+
+// The tasks that are not subtasks
+func (q *TaskQuery) TopLevel() *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return t.ParentId == "" })
+}
+
+// The tasks due before date, the tasks without due date are left out
+func (q *TaskQuery) DueBefore(date time.Time) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return !t.DueDate.IsZero() && t.DueDate.Before(date) })
+}
+
+// The tasks due at or after date, the tasks without due date are left out
+func (q *TaskQuery) DueAfter(date time.Time) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return !t.DueDate.IsZero() && !t.DueDate.Before(date) })
+}
+
+// The tasks starting before date, the tasks without start date are left out
+func (q *TaskQuery) StartBefore(date time.Time) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return !t.StartDate.IsZero() && t.StartDate.Before(date) })
+}
+
+// The tasks starting at or after date, the tasks without start date are left out
+func (q *TaskQuery) StartAfter(date time.Time) *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return !t.StartDate.IsZero() && !t.StartDate.Before(date) })
+}
+
+// The tasks without due date
+func (q *TaskQuery) NoDueDate() *TaskQuery {
+	return q.Where(func(t *TaskItem) bool { return t.DueDate.IsZero() })
+}
+
+// Order the results by the keys, the later keys break the ties of the earlier ones.
+// Without keys the results keep the order of the synced tasks.
+func (q *TaskQuery) Sorted(keys ...SortKey) *TaskQuery {
+	sorted := q.with(q.match)
+	sorted.sort = append([]SortKey(nil), keys...)
+	return sorted
+}
+
+// Keep the first n results, 0 keeps them all
+func (q *TaskQuery) Limit(n int) *TaskQuery {
+	limited := q.with(q.match)
+	limited.limit = n
+	return limited
+}
+
+// Whether the task matches the conditions of the query
+func (q *TaskQuery) Match(t TaskItem) bool {
+	return q.match(&t)
+}
+
+// The matching tasks, sorted and limited. The conditions and the results get copies,
+// which can be edited without changing tasks.
+func (q *TaskQuery) Apply(tasks []TaskItem) []TaskItem {
+	copies := make([]TaskItem, len(tasks))
+	for i := range tasks {
+		copies[i] = tasks[i].clone()
+	}
+	return q.apply(copies)
+}
+
+// Apply on tasks that belong to the caller
+func (q *TaskQuery) apply(tasks []TaskItem) []TaskItem {
+	var res []TaskItem
+	for i := range tasks {
+		if q.match(&tasks[i]) {
+			res = append(res, tasks[i])
+		}
+	}
+	if len(q.sort) > 0 {
+		sort.SliceStable(res, func(i, j int) bool {
+			for _, key := range q.sort {
+				if c := compareTasks(&res[i], &res[j], key); c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+	}
+	if q.limit > 0 && len(res) > q.limit {
+		res = res[:q.limit]
+	}
+	return res
+}
+
+func compareTasks(a, b *TaskItem, key SortKey) int {
+	switch key {
+	case ByDue:
+		return compareDates(a.DueDate, b.DueDate)
+	case ByStart:
+		return compareDates(a.StartDate, b.StartDate)
+	case ByPriority:
+		return compareInts(b.Priority, a.Priority)
+	case ByTitle:
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	case BySortOrder:
+		return compareInts(a.SortOrder, b.SortOrder)
+	}
+	return 0
+}
+
+// the unset dates are the last
+func compareDates(a, b TickTickTime) int {
+	switch {
+	case a.IsZero() && b.IsZero():
+		return 0
+	case a.IsZero():
+		return 1
+	case b.IsZero():
+		return -1
+	}
+	return a.Compare(b.Time)
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Run the query on the synced tasks, after syncing
+func (c *Client) QueryTasks(q *TaskQuery) ([]TaskItem, error) {
+	return c.QueryTasksCtx(context.Background(), q)
+}
+
+// QueryTasksCtx is QueryTasks with a context
+func (c *Client) QueryTasksCtx(ctx context.Context, q *TaskQuery) ([]TaskItem, error) {
+	if err := c.syncForQuery(ctx); err != nil {
+		return nil, err
+	}
+	return c.queryTasks(q)
+}
+
+// sync before querying the snapshot, the last one is queried if the sync fails, unless the
+// caller gave up or has to sign in
+func (c *Client) syncForQuery(ctx context.Context) error {
+	if err := c.SyncCtx(ctx); err != nil && (ctx.Err() != nil || errors.Is(err, ErrUnauthorized)) {
+		return err
+	}
+	return nil
+}
+
+// run the query on the snapshot as it is
+func (c *Client) queryTasks(q *TaskQuery) ([]TaskItem, error) {
+	if q.closed {
+		return nil, fmt.Errorf("%w: use ListCompletedTasks or ListAbandonedTasks", ErrClosedTasksNotSynced)
+	}
+
+	// the conditions run without the lock, they may call the client
+	c.mu.RLock()
+	tasks := make([]TaskItem, len(c.tasks))
+	for i := range c.tasks {
+		tasks[i] = c.tasks[i].clone()
+	}
+	c.mu.RUnlock()
+	return q.apply(tasks), nil
+}
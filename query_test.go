@@ -0,0 +1,158 @@
+package ticktick
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func queryTestTasks() []TaskItem {
+	return []TaskItem{
+		{Id: "1", Title: "Write report", ProjectId: "pid1", ProjectName: "work", Tags: []string{"urgent", "writing"}, Priority: 5, DueDate: MustParseTickTickTime("2023-01-03T10:00:00.000+0000"), StartDate: MustParseTickTickTime("2023-01-02T10:00:00.000+0000"), SortOrder: 3, Kind: "TEXT"},
+		{Id: "2", Title: "buy milk", ProjectId: "pid2", ProjectName: "home", Tags: []string{"Errand"}, Priority: 1, DueDate: MustParseTickTickTime("2023-01-01T10:00:00.000+0000"), SortOrder: 1, Kind: "TEXT"},
+		{Id: "3", Title: "Review PR", ProjectId: "pid1", ProjectName: "work", Tags: []string{"urgent"}, Priority: 3, Status: Completed, SortOrder: 2, Kind: "CHECKLIST"},
+		{Id: "4", Title: "write tests", ProjectId: "pid1", ProjectName: "work", ParentId: "1", Priority: 5, DueDate: MustParseTickTickTime("2023-01-02T10:00:00.000+0000"), SortOrder: 0, Kind: "TEXT"},
+		{Id: "5", Title: "old idea", ProjectId: "pid3", ProjectName: "someday", Status: Abandoned, SortOrder: 4, Kind: "NOTE"},
+	}
+}
+
+func ids(tasks []TaskItem) []string {
+	var res []string
+	for _, t := range tasks {
+		res = append(res, t.Id)
+	}
+	return res
+}
+
+func TestTaskQuery(t *testing.T) {
+	assert := assert.New(t)
+	tasks := queryTestTasks()
+	jan2 := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	jan3 := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name  string
+		query *TaskQuery
+		want  []string
+	}{
+		{"all", Query(), []string{"1", "2", "3", "4", "5"}},
+		{"ids", Query().WithIds("2", "5", "9"), []string{"2", "5"}},
+		{"title ignores case", Query().TitleContains("WRITE"), []string{"1", "4"}},
+		{"projects by name or id", Query().InProjects("home", "pid3"), []string{"2", "5"}},
+		{"any tag ignores case", Query().WithAnyTag("errand", "writing"), []string{"1", "2"}},
+		{"all tags", Query().WithAllTags("urgent", "writing"), []string{"1"}},
+		{"untagged", Query().Untagged(), []string{"4", "5"}},
+		{"status", Query().Status(Completed, Abandoned), []string{"3", "5"}},
+		{"priority", Query().Priority(5), []string{"1", "4"}},
+		{"kind", Query().Kind("CHECKLIST", "NOTE"), []string{"3", "5"}},
+		{"child of", Query().ChildOf("1"), []string{"4"}},
+		{"top level", Query().TopLevel().InProjects("work"), []string{"1", "3"}},
+		{"due before leaves out the undated tasks", Query().DueBefore(jan3), []string{"2", "4"}},
+		{"due after", Query().DueAfter(jan2), []string{"1", "4"}},
+		{"start before", Query().StartBefore(jan3), []string{"1"}},
+		{"start after", Query().StartAfter(jan3), nil},
+		{"no due date", Query().NoDueDate(), []string{"3", "5"}},
+		{"and", Query().InProjects("work").WithAnyTag("urgent").Status(Open), []string{"1"}},
+		{"and queries", Query().And(Query().Priority(5), Query().TopLevel()), []string{"1"}},
+		{"or", Query().InProjects("home").Or(Query().Status(Abandoned), Query().WithIds("4")), []string{"2", "4", "5"}},
+		{"or then and", Query().InProjects("home").Or(Query().InProjects("work")).Status(Open), []string{"1", "2", "4"}},
+		{"not", Query().InProjects("work").Not(Query().WithAnyTag("urgent")), []string{"4"}},
+		{"custom condition", Query().Where(func(t *TaskItem) bool { return len(t.Title) < 9 }), []string{"2", "5"}},
+		{"sorted by due", Query().Sorted(ByDue), []string{"2", "4", "1", "3", "5"}},
+		{"sorted by start", Query().Sorted(ByStart), []string{"1", "2", "3", "4", "5"}},
+		{"sorted by priority then title", Query().Sorted(ByPriority, ByTitle), []string{"1", "4", "3", "2", "5"}},
+		{"sorted by title", Query().Sorted(ByTitle), []string{"2", "5", "3", "1", "4"}},
+		{"sorted by sort order", Query().Sorted(BySortOrder), []string{"4", "2", "3", "1", "5"}},
+		{"limit", Query().Status(Open).Sorted(ByDue).Limit(2), []string{"2", "4"}},
+		{"nothing", Query().InProjects("random"), nil},
+	}
+	for _, tc := range testCases {
+		assert.Equal(tc.want, ids(tc.query.Apply(tasks)), tc.name)
+	}
+
+	// a single task
+	assert.True(Query().WithAnyTag("urgent").Match(tasks[0]))
+	assert.False(Query().WithAnyTag("urgent").Match(tasks[1]))
+
+	// the tasks are not modified
+	assert.Equal(queryTestTasks(), tasks)
+
+	// branches of a shared base query
+	base := Query().InProjects("work").Sorted(ByTitle)
+	open := base.Status(Open)
+	closed := base.Status(Completed).Limit(1)
+	assert.Equal([]string{"1", "4"}, ids(open.Apply(tasks)))
+	assert.Equal([]string{"3"}, ids(closed.Apply(tasks)))
+	assert.Equal([]string{"3", "1", "4"}, ids(base.Apply(tasks)))
+	or := base.Or(Query().InProjects("home"))
+	assert.Equal([]string{"2", "3", "1", "4"}, ids(or.Apply(tasks)))
+
+	// a query combined with itself
+	q := Query().InProjects("home")
+	assert.Equal([]string{"2"}, ids(q.Or(q).Apply(tasks)))
+	assert.Equal([]string{"2"}, ids(q.And(q).Apply(tasks)))
+	assert.Nil(q.Not(q).Apply(tasks))
+	q = q.Or(q.Not(q))
+	assert.Equal([]string{"2"}, ids(q.Apply(tasks)))
+}
+
+func TestQueryTasks(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+
+	// normal case
+	NewSyncTestServer(BuildSyncResponse())
+	tasks, err := client.QueryTasks(Query().InProjects("pname1").WithAnyTag("b").Sorted(ByPriority))
+	assert.Nil(err)
+	assert.Equal([]string{"1", "2"}, ids(tasks))
+	assert.Equal("pname1", tasks[0].ProjectName)
+
+	// the snapshot is queried when the sync fails
+	gock.New(baseUrlV2Test).
+		Get("/batch/check/0").
+		Reply(500)
+	tasks, err = client.QueryTasks(Query().StartAfter(time.Date(2022, 12, 14, 0, 0, 0, 0, time.UTC)))
+	assert.Nil(err)
+	assert.Equal([]string{"3"}, ids(tasks))
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.QueryTasksCtx(ctx, Query())
+	assert.NotNil(err)
+
+	// the closed tasks are not synced
+	for _, q := range []*TaskQuery{
+		Query().Status(Completed),
+		Query().Status(Open, Abandoned),
+		Query().Priority(5).Or(Query().Status(Completed)),
+		Query().And(Query().Status(Abandoned)).Sorted(ByDue),
+	} {
+		_, err = client.QueryTasks(q)
+		assert.True(errors.Is(err, ErrClosedTasksNotSynced))
+	}
+	tasks, err = client.QueryTasks(Query().Status(Open).Not(Query().Status(Completed)))
+	assert.Nil(err)
+	assert.Len(tasks, 3)
+	assert.Len(Query().Status(Completed).Apply([]TaskItem{{Status: Completed}, {}}), 1)
+
+	// the conditions get copies, and may call the client
+	tasks, err = client.QueryTasks(Query().Where(func(t *TaskItem) bool {
+		t.Title = "edited"
+		t.Tags[0] = "edited"
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return t.Id == "1"
+	}))
+	assert.Nil(err)
+	if assert.Len(tasks, 1) {
+		assert.Equal("edited", tasks[0].Title)
+	}
+	assert.Equal("1", client.tasks[0].Title)
+	assert.Equal([]string{"a", "b"}, client.tasks[0].Tags)
+}
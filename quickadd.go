@@ -0,0 +1,629 @@
+package ticktick
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// quickAdd is the state of a quick add input being parsed, the recognized parts are cut from text
+type quickAdd struct {
+	text  string
+	now   time.Time // in loc
+	today time.Time
+
+	date    time.Time // the zero time if none
+	hasTime bool      // date has a time, else it is a day
+	hour    int       // of the time parsed apart from the date, -1 if none
+	minute  int
+	repeat  *RRule
+}
+
+// a pattern of the input, apply returns false when the match is not what the pattern
+// looks for after all, e.g. February 30
+type quickAddPattern struct {
+	re    *regexp.Regexp
+	apply func(p *quickAdd, m []string) bool
+}
+
+// ParseQuickAdd turns a line typed like in the quick add bar of the app into a task ready
+// to be created, e.g. "Pay rent tomorrow 9am #finance !high ^Personal". The recognized parts
+// are cut from the title:
+//
+//   - #tag, any number of them
+//   - !priority: high, medium, low, none or 5, 3, 1, 0, and 高, 中, 低, 无
+//   - ^project, the longest project name, spaces included; the task goes to the inbox without it.
+//     The client syncs once to look for a project missing from the synced ones.
+//   - ~section, the longest section name of the project, spaces included, it sets the ColumnId.
+//     The sections are fetched from the server, and "buy ~5 apples" keeps its ~5.
+//   - a date: today, tomorrow, day after tomorrow, monday (the next one, today included),
+//     next monday (in the next week), this monday, next week, next month, in 3 days, in 2 hours,
+//     2023-01-06, 1/6 (month first), jan 6, 6 january 2024, and 今天, 明天, 后天, 大后天, 周一,
+//     下周一, 本周一, 下周, 下个月, 3天后, 2小时后, 2024年1月6日, 1月6号
+//   - a time: 9am, 9:30 pm, 21:00, at 9, noon, and 上午9点, 下午3点半, 晚上8点30分, 今晚 (20:00)
+//   - a repeat: daily, weekly, monthly, yearly, every day, every other week, every 3 months,
+//     every weekday, every monday and friday, and 每天, 每周, 每月, 每年, 每两周, 每隔一天
+//     (every other day), 工作日, 每周一三五
+//
+// The dates are in loc and relative to now, a date without a year is the next one. The task is
+// all day unless a time is given, a task with a time only is due today, and a repeating task
+// without date starts with its first occurrence from today. Unknown !words are left in the title.
+func ParseQuickAdd(c *Client, input string, now time.Time, loc *time.Location) (*TaskItem, error) {
+	return ParseQuickAddCtx(context.Background(), c, input, now, loc)
+}
+
+// ParseQuickAddCtx is ParseQuickAdd with a context
+func ParseQuickAddCtx(ctx context.Context, c *Client, input string, now time.Time, loc *time.Location) (*TaskItem, error) {
+	now = now.In(loc)
+	p := &quickAdd{
+		text:  input,
+		now:   now,
+		today: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc),
+		hour:  -1,
+	}
+
+	projectName, err := p.project(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	columnId, err := p.section(ctx, c, projectName)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	var priority int64
+	for {
+		m := quickAddMarker.FindStringSubmatchIndex(p.text)
+		if m == nil {
+			break
+		}
+		marker, value := p.text[m[2]:m[3]], p.text[m[4]:m[5]]
+		switch marker {
+		case "#":
+			if !Contains(tags, value) {
+				tags = append(tags, value)
+			}
+		case "!":
+			pr, ok := quickAddPriorities[strings.ToLower(value)]
+			if !ok {
+				// not a priority, protect it from the next searches
+				p.text = p.text[:m[2]] + "\x00" + p.text[m[3]:]
+				continue
+			}
+			priority = pr
+		}
+		p.cut(m[2], m[1])
+	}
+	p.text = strings.ReplaceAll(p.text, "\x00", "!")
+
+	p.match(repeatPatterns)
+	p.match(datePatterns)
+	p.match(timePatterns)
+
+	title := strings.Join(strings.Fields(p.text), " ")
+	if title == "" {
+		return nil, fmt.Errorf("the title of %q is empty", input)
+	}
+	t, err := NewTask(c, title, "", time.Time{}, projectName)
+	if err != nil {
+		return nil, err
+	}
+	t.ColumnId = columnId
+	t.Tags = tags
+	t.Priority = priority
+
+	date, hasTime := p.when()
+	if !date.IsZero() {
+		t.SetDue(date, !hasTime)
+	}
+	if p.repeat != nil {
+		t.SetRepeat(p.repeat, RepeatFromDueDate)
+	}
+	return t, nil
+}
+
+var (
+	quickAddMarker     = regexp.MustCompile(`(?:^|\s)([#!])(\S+)`)
+	quickAddProject    = regexp.MustCompile(`(?:^|\s)\^`)
+	quickAddSection    = regexp.MustCompile(`(?:^|\s)~[^\s\d]`) // ~5 is not a section
+	quickAddPriorities = map[string]int64{
+		"high": 5, "medium": 3, "med": 3, "low": 1, "none": 0,
+		"5": 5, "3": 3, "1": 1, "0": 0,
+		"高": 5, "中": 3, "低": 1, "无": 0,
+	}
+)
+
+// cut the project, the longest project name after ^, or the next word if none matches.
+// The client syncs when no synced project matches, the project may be new.
+func (p *quickAdd) project(ctx context.Context, c *Client) (string, error) {
+	loc := quickAddProject.FindStringIndex(p.text)
+	if loc == nil {
+		return "", nil
+	}
+	rest := p.text[loc[1]:]
+	found := longestName(rest, c.projectNames())
+	if found == "" {
+		if err := c.SyncCtx(ctx); err != nil {
+			return "", err
+		}
+		found = longestName(rest, c.projectNames())
+	}
+	if found == "" {
+		word, _, _ := strings.Cut(rest, " ")
+		return "", fmt.Errorf("%w: %v", ErrProjectNotFound, word)
+	}
+	p.cut(loc[0], loc[1]+len(found))
+	return found, nil
+}
+
+// cut the section, the longest section name of the project after ~, and return its id
+func (p *quickAdd) section(ctx context.Context, c *Client, projectName string) (string, error) {
+	loc := quickAddSection.FindStringIndex(p.text)
+	if loc == nil {
+		return "", nil
+	}
+	start := loc[1] - 1 // after the ~
+	rest := p.text[start:]
+	if projectName == "" {
+		projectName = "inbox"
+	}
+	sections, err := c.ListSectionsCtx(ctx, projectName)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(sections))
+	for _, s := range sections {
+		names = append(names, s.Name)
+	}
+	found := longestName(rest, names)
+	if found == "" {
+		word, _, _ := strings.Cut(rest, " ")
+		return "", fmt.Errorf("%w: %v in %v", ErrSectionNotFound, word, projectName)
+	}
+	p.cut(loc[0], start+len(found))
+	for _, s := range sections {
+		if s.Name == found {
+			return s.Id, nil
+		}
+	}
+	return "", nil
+}
+
+// the longest of the names starting text, case insensitively and followed by a space or the end
+func longestName(text string, names []string) string {
+	found := ""
+	for _, name := range names {
+		if len(name) > len(found) && len(text) >= len(name) && strings.EqualFold(text[:len(name)], name) {
+			if next, _ := utf8.DecodeRuneInString(text[len(name):]); len(text) == len(name) || next == ' ' || next == '\t' {
+				found = name
+			}
+		}
+	}
+	return found
+}
+
+// replace text[start:end] with a space
+func (p *quickAdd) cut(start, end int) {
+	p.text = p.text[:start] + " " + p.text[end:]
+}
+
+// apply the first pattern matching the text, and cut its match
+func (p *quickAdd) match(patterns []quickAddPattern) {
+	for _, pattern := range patterns {
+		for _, loc := range pattern.re.FindAllStringSubmatchIndex(p.text, -1) {
+			m := make([]string, len(loc)/2)
+			for i := range m {
+				if loc[2*i] >= 0 {
+					m[i] = p.text[loc[2*i]:loc[2*i+1]]
+				}
+			}
+			if pattern.apply(p, m) {
+				p.cut(loc[0], loc[1])
+				return
+			}
+		}
+	}
+}
+
+// the due date, and whether it has a time
+func (p *quickAdd) when() (time.Time, bool) {
+	date, hasTime := p.date, p.hasTime
+	if date.IsZero() && p.repeat != nil {
+		date = p.firstOccurrence()
+	}
+	if p.hour < 0 || hasTime {
+		return date, hasTime
+	}
+	if date.IsZero() {
+		date = p.today
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), p.hour, p.minute, 0, 0, date.Location()), true
+}
+
+// the first day from today on the days of a weekly repeat, else today
+func (p *quickAdd) firstOccurrence() time.Time {
+	if p.repeat.Freq != Weekly || len(p.repeat.ByDay) == 0 {
+		return p.today
+	}
+	for d := p.today; ; d = d.AddDate(0, 0, 1) {
+		for _, day := range p.repeat.ByDay {
+			if d.Weekday() == day.Weekday {
+				return d
+			}
+		}
+	}
+}
+
+// set a day, false if it does not exist
+func (p *quickAdd) setDay(year int, month time.Month, day int) bool {
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.today.Location())
+	if date.Month() != month || date.Day() != day {
+		return false
+	}
+	p.date = date
+	return true
+}
+
+// set a day of a year that may be omitted, then it is the next one from today
+func (p *quickAdd) setDayOfYear(year string, month time.Month, day int) bool {
+	if year != "" {
+		y, _ := strconv.Atoi(year)
+		if y < 100 {
+			y += 2000
+		}
+		return p.setDay(y, month, day)
+	}
+	// February 29 can be years away
+	for y := p.today.Year(); y < p.today.Year()+8; y++ {
+		date := time.Date(y, month, day, 0, 0, 0, 0, p.today.Location())
+		if date.Month() == month && date.Day() == day && !date.Before(p.today) {
+			p.date = date
+			return true
+		}
+	}
+	return false
+}
+
+// the weekday in the week of today (starting on Monday), shifted by weeks
+func (p *quickAdd) weekday(day time.Weekday, weeks int) time.Time {
+	monday := p.today.AddDate(0, 0, -(int(p.today.Weekday())+6)%7)
+	return monday.AddDate(0, 0, (int(day)+6)%7+7*weeks)
+}
+
+// the next weekday, today included
+func (p *quickAdd) nextWeekday(day time.Weekday) time.Time {
+	return p.today.AddDate(0, 0, (int(day)-int(p.today.Weekday())+7)%7)
+}
+
+// add n units to today, or to now for the hours and the minutes
+func (p *quickAdd) setRelative(n int, unit string) bool {
+	switch unit {
+	case "minute", "min", "分钟":
+		p.date, p.hasTime = p.now.Add(time.Duration(n)*time.Minute), true
+	case "hour", "hr", "小时":
+		p.date, p.hasTime = p.now.Add(time.Duration(n)*time.Hour), true
+	case "day", "天", "日":
+		p.date = p.today.AddDate(0, 0, n)
+	case "week", "周", "星期", "礼拜":
+		p.date = p.today.AddDate(0, 0, 7*n)
+	case "month", "月":
+		p.date = p.today.AddDate(0, n, 0)
+	case "year", "年":
+		p.date = p.today.AddDate(n, 0, 0)
+	default:
+		return false
+	}
+	return true
+}
+
+// set the time parsed apart from the date
+func (p *quickAdd) setTime(hour, minute int) bool {
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return false
+	}
+	p.hour, p.minute = hour, minute
+	return true
+}
+
+// set a repeat rule
+func (p *quickAdd) setRepeat(freq Frequency, interval int, days ...time.Weekday) bool {
+	if interval < 1 {
+		return false
+	}
+	rule := &RRule{Freq: freq}
+	if interval > 1 {
+		rule.Interval = interval
+	}
+	for _, d := range days {
+		rule.ByDay = append(rule.ByDay, RRuleDay{Weekday: d})
+	}
+	p.repeat = rule
+	return true
+}
+
+const (
+	enWeekdayFull = `monday|tuesday|wednesday|thursday|friday|saturday|sunday`
+	enWeekday     = enWeekdayFull + `|mon|tues|tue|wed|thurs|thur|thu|fri|sat|sun`
+	enMonth       = `january|february|march|april|may|june|july|august|september|october|november|december|jan|feb|mar|apr|jun|jul|aug|sept|sep|oct|nov|dec`
+	enNumber      = `\d+|an?|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve`
+	enDatePrefix  = `(?:\b(?:on|by|due)\s+)?`
+	cnWeekday     = `[一二三四五六日天1-7]`
+	cnNumber      = `[0-9]+|[零一二两三四五六七八九十]+`
+)
+
+var enWeekdayRe = regexp.MustCompile(`(?i)\b(` + enWeekday + `)\b`)
+
+var enWeekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var cnWeekdays = map[string]time.Weekday{
+	"一": time.Monday, "二": time.Tuesday, "三": time.Wednesday, "四": time.Thursday,
+	"五": time.Friday, "六": time.Saturday, "日": time.Sunday, "天": time.Sunday,
+	"1": time.Monday, "2": time.Tuesday, "3": time.Wednesday, "4": time.Thursday,
+	"5": time.Friday, "6": time.Saturday, "7": time.Sunday,
+}
+
+var enNumbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+func parseEnNumber(s string) int {
+	if n, ok := enNumbers[strings.ToLower(s)]; ok {
+		return n
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// a number in digits or in Chinese numerals up to 99, -1 if it is not one
+func parseCnNumber(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	digits := map[rune]int{'零': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	runes := []rune(s)
+	if len(runes) == 0 || len(runes) > 3 {
+		return -1
+	}
+	n, tens := 0, false
+	for i, r := range runes {
+		if r == '十' {
+			if tens {
+				return -1
+			}
+			tens = true
+			if i == 0 {
+				n = 1
+			}
+			n *= 10
+			continue
+		}
+		d, ok := digits[r]
+		if !ok {
+			return -1
+		}
+		n += d
+	}
+	return n
+}
+
+func enMonthOf(s string) time.Month {
+	s = strings.ToLower(s)
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), s[:3]) {
+			return m
+		}
+	}
+	return 0
+}
+
+func enWeekdayList(s string) []time.Weekday {
+	var days []time.Weekday
+	for _, name := range enWeekdayRe.FindAllString(s, -1) {
+		days = append(days, enWeekdays[strings.ToLower(name)])
+	}
+	return days
+}
+
+func cnWeekdayList(s string) []time.Weekday {
+	var days []time.Weekday
+	for _, r := range s {
+		if d, ok := cnWeekdays[string(r)]; ok {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+var repeatPatterns = []quickAddPattern{
+	{regexp.MustCompile(`(?i)\bevery\s+weekdays?\b|(?:每个?)?工作日`), func(p *quickAdd, m []string) bool {
+		return p.setRepeat(Weekly, 1, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+	}},
+	{regexp.MustCompile(`(?i)\bevery\s+weekends?\b|每个?周末`), func(p *quickAdd, m []string) bool {
+		return p.setRepeat(Weekly, 1, time.Saturday, time.Sunday)
+	}},
+	{regexp.MustCompile(`(?i)\bevery\s+((?:` + enWeekday + `)(?:\s*(?:,|&|and)\s*(?:` + enWeekday + `))*)\b`), func(p *quickAdd, m []string) bool {
+		return p.setRepeat(Weekly, 1, enWeekdayList(m[1])...)
+	}},
+	{regexp.MustCompile(`每个?(?:周|星期|礼拜)(` + cnWeekday + `(?:[、,，和]?` + cnWeekday + `)*)`), func(p *quickAdd, m []string) bool {
+		return p.setRepeat(Weekly, 1, cnWeekdayList(m[1])...)
+	}},
+	{regexp.MustCompile(`(?i)\bevery\s+(?:(other)\s+|(` + enNumber + `)\s+)?(day|week|month|year)s?\b`), func(p *quickAdd, m []string) bool {
+		interval := 1
+		if m[1] != "" {
+			interval = 2
+		} else if m[2] != "" {
+			interval = parseEnNumber(m[2])
+		}
+		return p.setRepeat(map[string]Frequency{"day": Daily, "week": Weekly, "month": Monthly, "year": Yearly}[strings.ToLower(m[3])], interval)
+	}},
+	{regexp.MustCompile(`(?i)\b(daily|weekly|monthly|yearly|annually)\b`), func(p *quickAdd, m []string) bool {
+		freq := map[string]Frequency{"daily": Daily, "weekly": Weekly, "monthly": Monthly, "yearly": Yearly, "annually": Yearly}
+		return p.setRepeat(freq[strings.ToLower(m[1])], 1)
+	}},
+	// 每隔一天 is every other day
+	{regexp.MustCompile(`每(隔)?(` + cnNumber + `)?个?(天|日|周|星期|礼拜|月|年)`), func(p *quickAdd, m []string) bool {
+		interval := 1
+		if m[2] != "" {
+			interval = parseCnNumber(m[2])
+		}
+		if m[1] != "" {
+			interval++
+		}
+		freq := map[string]Frequency{"天": Daily, "日": Daily, "周": Weekly, "星期": Weekly, "礼拜": Weekly, "月": Monthly, "年": Yearly}
+		return p.setRepeat(freq[m[3]], interval)
+	}},
+}
+
+var datePatterns = []quickAddPattern{
+	{regexp.MustCompile(`(?i)` + enDatePrefix + `\b(?:the\s+)?day\s+after\s+tomorrow\b|大后天|后天`), func(p *quickAdd, m []string) bool {
+		days := 2
+		if m[0] == "大后天" {
+			days = 3
+		}
+		p.date = p.today.AddDate(0, 0, days)
+		return true
+	}},
+	{regexp.MustCompile(`(?i)` + enDatePrefix + `\b(today|tonight|tomorrow|tmrw|tmr)\b|今天|今日|今晚|明天|明日`), func(p *quickAdd, m []string) bool {
+		day := m[1]
+		if day == "" {
+			day = m[0]
+		}
+		switch strings.ToLower(day) {
+		case "tonight", "今晚":
+			// the time patterns come later, a given time wins
+			p.date, p.hour = p.today, 20
+		case "today", "今天", "今日":
+			p.date = p.today
+		default:
+			p.date = p.today.AddDate(0, 0, 1)
+		}
+		return true
+	}},
+	{regexp.MustCompile(`(?i)\bin\s+(` + enNumber + `)\s+(minute|min|hour|hr|day|week|month|year)s?\b`), func(p *quickAdd, m []string) bool {
+		return p.setRelative(parseEnNumber(m[1]), strings.ToLower(m[2]))
+	}},
+	{regexp.MustCompile(`(` + cnNumber + `)个?(分钟|小时|天|日|周|星期|礼拜|月|年)(?:以后|之后|后)`), func(p *quickAdd, m []string) bool {
+		n := parseCnNumber(m[1])
+		return n >= 0 && p.setRelative(n, m[2])
+	}},
+	{regexp.MustCompile(`(?i)` + enDatePrefix + `\b(next|this)\s+(` + enWeekday + `)\b`), func(p *quickAdd, m []string) bool {
+		weeks := 0
+		if strings.EqualFold(m[1], "next") {
+			weeks = 1
+		}
+		p.date = p.weekday(enWeekdays[strings.ToLower(m[2])], weeks)
+		return true
+	}},
+	{regexp.MustCompile(`(?i)\b(?:on|by|due)\s+(` + enWeekday + `)\b|\b(` + enWeekdayFull + `)\b`), func(p *quickAdd, m []string) bool {
+		p.date = p.nextWeekday(enWeekdays[strings.ToLower(m[1]+m[2])])
+		return true
+	}},
+	{regexp.MustCompile(`(下个?|本|这个?)?(?:周|星期|礼拜)(` + cnWeekday + `)`), func(p *quickAdd, m []string) bool {
+		switch m[1] {
+		case "":
+			p.date = p.nextWeekday(cnWeekdays[m[2]])
+		case "本", "这", "这个":
+			p.date = p.weekday(cnWeekdays[m[2]], 0)
+		default:
+			p.date = p.weekday(cnWeekdays[m[2]], 1)
+		}
+		return true
+	}},
+	{regexp.MustCompile(`(?i)\bnext\s+(week|month|year)\b|(下个?(?:周|星期|礼拜)|下个?月|明年)`), func(p *quickAdd, m []string) bool {
+		switch {
+		case strings.EqualFold(m[1], "week") || strings.HasSuffix(m[2], "周") || strings.HasSuffix(m[2], "星期") || strings.HasSuffix(m[2], "礼拜"):
+			p.date = p.weekday(time.Monday, 1)
+		case strings.EqualFold(m[1], "month") || strings.HasSuffix(m[2], "月"):
+			p.date = time.Date(p.today.Year(), p.today.Month()+1, 1, 0, 0, 0, 0, p.today.Location())
+		default:
+			p.date = time.Date(p.today.Year()+1, time.January, 1, 0, 0, 0, 0, p.today.Location())
+		}
+		return true
+	}},
+	{regexp.MustCompile(enDatePrefix + `\b(\d{4})[-/](\d{1,2})[-/](\d{1,2})\b`), func(p *quickAdd, m []string) bool {
+		y, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		d, _ := strconv.Atoi(m[3])
+		return p.setDay(y, time.Month(month), d)
+	}},
+	{regexp.MustCompile(`(?i)` + enDatePrefix + `\b(\d{1,2})/(\d{1,2})(?:/(\d{4}|\d{2}))?\b`), func(p *quickAdd, m []string) bool {
+		month, _ := strconv.Atoi(m[1])
+		d, _ := strconv.Atoi(m[2])
+		return p.setDayOfYear(m[3], time.Month(month), d)
+	}},
+	{regexp.MustCompile(`(?i)` + enDatePrefix + `\b(` + enMonth + `)\.?\s+(\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(\d{4}))?\b`), func(p *quickAdd, m []string) bool {
+		d, _ := strconv.Atoi(m[2])
+		return p.setDayOfYear(m[3], enMonthOf(m[1]), d)
+	}},
+	{regexp.MustCompile(`(?i)` + enDatePrefix + `\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?(` + enMonth + `)\b(?:,?\s+(\d{4})\b)?`), func(p *quickAdd, m []string) bool {
+		d, _ := strconv.Atoi(m[1])
+		return p.setDayOfYear(m[3], enMonthOf(m[2]), d)
+	}},
+	{regexp.MustCompile(`(?:(\d{4})年)?(` + cnNumber + `)月(` + cnNumber + `)[日号]`), func(p *quickAdd, m []string) bool {
+		month, d := parseCnNumber(m[2]), parseCnNumber(m[3])
+		return month > 0 && d > 0 && p.setDayOfYear(m[1], time.Month(month), d)
+	}},
+}
+
+var timePatterns = []quickAddPattern{
+	{regexp.MustCompile(`(?i)(?:\bat\s+)?\b(\d{1,2})(?::(\d{2}))?\s*([ap])\.?m\b\.?`), func(p *quickAdd, m []string) bool {
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour < 1 || hour > 12 {
+			return false
+		}
+		hour %= 12
+		if strings.EqualFold(m[3], "p") {
+			hour += 12
+		}
+		return p.setTime(hour, minute)
+	}},
+	{regexp.MustCompile(`(?i)(?:\bat\s+)?\b(\d{1,2}):(\d{2})\b`), func(p *quickAdd, m []string) bool {
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		return p.setTime(hour, minute)
+	}},
+	{regexp.MustCompile(`(?i)\bat\s+(\d{1,2})\b|(?:\bat\s+)?\b(noon)\b`), func(p *quickAdd, m []string) bool {
+		if m[2] != "" {
+			return p.setTime(12, 0)
+		}
+		hour, _ := strconv.Atoi(m[1])
+		return p.setTime(hour, 0)
+	}},
+	{regexp.MustCompile(`(凌晨|早上|早晨|上午|中午|下午|傍晚|晚上)?(` + cnNumber + `)[点點](半|(` + cnNumber + `)分?)?`), func(p *quickAdd, m []string) bool {
+		if m[1] == "" && m[2] == "一" && m[3] == "" {
+			// 一点 is "a bit" more often than one o'clock
+			return false
+		}
+		hour, minute := parseCnNumber(m[2]), 0
+		if m[3] == "半" {
+			minute = 30
+		} else if m[4] != "" {
+			minute = parseCnNumber(m[4])
+		}
+		switch m[1] {
+		case "下午", "傍晚", "晚上":
+			if hour < 12 {
+				hour += 12
+			}
+		case "中午":
+			if hour < 11 {
+				hour += 12
+			}
+		}
+		return p.setTime(hour, minute)
+	}},
+}
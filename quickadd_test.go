@@ -0,0 +1,211 @@
+package ticktick
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+	client.storeProject(Project{Id: "pid3", Name: "Side Projects"}, false)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	// a Wednesday
+	now := time.Date(2023, 1, 4, 10, 0, 0, 0, shanghai)
+
+	testCases := []struct {
+		input    string
+		title    string
+		due      string // 2006-01-02 for the all day tasks, else 2006-01-02 15:04
+		repeat   string
+		tags     []string
+		priority int64
+		project  string
+	}{
+		// markers
+		{input: "Pay rent tomorrow 9am #finance !high ^pname1", title: "Pay rent", due: "2023-01-05 09:00", tags: []string{"finance"}, priority: 5, project: "pname1"},
+		{input: "call mom", title: "call mom"},
+		{input: "#a write #b #a report !3", title: "write report", tags: []string{"a", "b"}, priority: 3},
+		{input: "hello! world !nope", title: "hello! world !nope"},
+		{input: "fix bug ^side projects #dev", title: "fix bug", tags: []string{"dev"}, project: "Side Projects"},
+		{input: "^PNAME2 task", title: "task", project: "pname2"},
+		{input: "^inbox task", title: "task", project: "inbox"},
+		{input: "buy ~5 apples", title: "buy ~5 apples"},
+
+		// english dates
+		{input: "report today", title: "report", due: "2023-01-04"},
+		{input: "report due tomorrow", title: "report", due: "2023-01-05"},
+		{input: "party tonight", title: "party", due: "2023-01-04 20:00"},
+		{input: "party tonight at 9pm", title: "party", due: "2023-01-04 21:00"},
+		{input: "trip day after tomorrow", title: "trip", due: "2023-01-06"},
+		{input: "meet friday", title: "meet", due: "2023-01-06"},
+		{input: "meet Wednesday", title: "meet", due: "2023-01-04"},
+		{input: "meet on mon", title: "meet", due: "2023-01-09"},
+		{input: "meet next friday", title: "meet", due: "2023-01-13"},
+		{input: "meet this monday", title: "meet", due: "2023-01-02"},
+		{input: "watch the sun", title: "watch the sun"},
+		{input: "review next week", title: "review", due: "2023-01-09"},
+		{input: "bills next month", title: "bills", due: "2023-02-01"},
+		{input: "plan next year", title: "plan", due: "2024-01-01"},
+		{input: "call in 3 days", title: "call", due: "2023-01-07"},
+		{input: "call in two weeks", title: "call", due: "2023-01-18"},
+		{input: "call in 2 hours", title: "call", due: "2023-01-04 12:00"},
+		{input: "renew in a month", title: "renew", due: "2023-02-04"},
+		{input: "launch 2023-03-15 14:30", title: "launch", due: "2023-03-15 14:30"},
+		{input: "launch on 2023/3/15", title: "launch", due: "2023-03-15"},
+		{input: "dentist 1/2", title: "dentist", due: "2024-01-02"},
+		{input: "dentist 2/28/24", title: "dentist", due: "2024-02-28"},
+		{input: "birthday jan 10th", title: "birthday", due: "2023-01-10"},
+		{input: "birthday Sept. 3", title: "birthday", due: "2023-09-03"},
+		{input: "birthday 5 march 2024", title: "birthday", due: "2024-03-05"},
+		{input: "leap day feb 29", title: "leap day", due: "2024-02-29"},
+		{input: "party feb 30", title: "party feb 30"},
+
+		// english times
+		{input: "standup at 9:30", title: "standup", due: "2023-01-04 09:30"},
+		{input: "standup 9:30 p.m.", title: "standup", due: "2023-01-04 21:30"},
+		{input: "lunch at noon friday", title: "lunch", due: "2023-01-06 12:00"},
+		{input: "gym at 18", title: "gym", due: "2023-01-04 18:00"},
+		{input: "gym 25:00", title: "gym 25:00"},
+
+		// english repeats
+		{input: "run daily", title: "run", due: "2023-01-04", repeat: "RRULE:FREQ=DAILY;INTERVAL=1"},
+		{input: "standup every weekday 9:30am", title: "standup", due: "2023-01-04 09:30", repeat: "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,TU,WE,TH,FR"},
+		{input: "yoga every tue and thu", title: "yoga", due: "2023-01-05", repeat: "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=TU,TH"},
+		{input: "pay every other week", title: "pay", due: "2023-01-04", repeat: "RRULE:FREQ=WEEKLY;INTERVAL=2"},
+		{input: "backup every 3 months starting feb 1", title: "backup starting", due: "2023-02-01", repeat: "RRULE:FREQ=MONTHLY;INTERVAL=3"},
+		{input: "clean every weekend", title: "clean", due: "2023-01-07", repeat: "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=SA,SU"},
+		{input: "taxes annually", title: "taxes", due: "2023-01-04", repeat: "RRULE:FREQ=YEARLY;INTERVAL=1"},
+
+		// chinese
+		{input: "明天下午3点开会 #工作 !高", title: "开会", due: "2023-01-05 15:00", tags: []string{"工作"}, priority: 5},
+		{input: "后天交报告", title: "交报告", due: "2023-01-06"},
+		{input: "大后天", title: "", due: ""},
+		{input: "下周一上午10点半 周会", title: "周会", due: "2023-01-09 10:30"},
+		{input: "周五 聚餐", title: "聚餐", due: "2023-01-06"},
+		{input: "本周一 复盘", title: "复盘", due: "2023-01-02"},
+		{input: "下周 出差", title: "出差", due: "2023-01-09"},
+		{input: "下个月 体检", title: "体检", due: "2023-02-01"},
+		{input: "3天后 体检", title: "体检", due: "2023-01-07"},
+		{input: "两小时后 取快递", title: "取快递", due: "2023-01-04 12:00"},
+		{input: "2023年3月8日 妇女节", title: "妇女节", due: "2023-03-08"},
+		{input: "1月2号还款", title: "还款", due: "2024-01-02"},
+		{input: "中午12点 午饭", title: "午饭", due: "2023-01-04 12:00"},
+		{input: "今晚 看电影", title: "看电影", due: "2023-01-04 20:00"},
+		{input: "明天晚上八点三十分 打电话", title: "打电话", due: "2023-01-05 20:30"},
+		{input: "快一点完成", title: "快一点完成"},
+		{input: "每天 背单词", title: "背单词", due: "2023-01-04", repeat: "RRULE:FREQ=DAILY;INTERVAL=1"},
+		{input: "每周一三五 跑步 晚上8点", title: "跑步", due: "2023-01-04 20:00", repeat: "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,WE,FR"},
+		{input: "每隔一天 浇花", title: "浇花", due: "2023-01-04", repeat: "RRULE:FREQ=DAILY;INTERVAL=2"},
+		{input: "每两周 理发", title: "理发", due: "2023-01-04", repeat: "RRULE:FREQ=WEEKLY;INTERVAL=2"},
+		{input: "每个月 交房租 ^pname1", title: "交房租", due: "2023-01-04", repeat: "RRULE:FREQ=MONTHLY;INTERVAL=1", project: "pname1"},
+		{input: "工作日 打卡", title: "打卡", due: "2023-01-04", repeat: "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,TU,WE,TH,FR"},
+	}
+	for _, tc := range testCases {
+		task, err := ParseQuickAdd(client, tc.input, now, shanghai)
+		if tc.title == "" {
+			assert.NotNil(err, tc.input)
+			continue
+		}
+		if !assert.Nil(err, tc.input) {
+			continue
+		}
+		assert.Equal(tc.title, task.Title, tc.input)
+		assert.Equal(tc.tags, task.Tags, tc.input)
+		assert.Equal(tc.priority, task.Priority, tc.input)
+		assert.Equal(tc.project, task.ProjectName, tc.input)
+		assert.Equal(tc.repeat, task.Repeat, tc.input)
+		due := ""
+		if task.IsAllDay {
+			due = task.DueIn(shanghai).Format("2006-01-02")
+		} else if !task.DueDate.IsZero() {
+			due = task.DueIn(shanghai).Format("2006-01-02 15:04")
+		}
+		assert.Equal(tc.due, due, tc.input)
+	}
+
+	// ready to create
+	task, err := ParseQuickAdd(client, "standup every weekday 9:30am ^pname2", now, shanghai)
+	assert.Nil(err)
+	assert.Equal("pid2", task.ProjectId)
+	assert.Equal("Asia/Shanghai", task.TimeZone)
+	assert.False(task.IsAllDay)
+	assert.Equal(task.DueDate, task.StartDate)
+	assert.Equal("2023-01-04T01:30:00.000+0000", task.DueDate.String())
+	assert.Equal(RepeatFromDueDate, task.RepeatFrom)
+	task, _ = ParseQuickAdd(client, "call mom", now, shanghai)
+	assert.Empty(task.ProjectId)
+	assert.True(task.DueDate.IsZero())
+
+	// project not found, even after a sync
+	NewSyncTestServer(BuildSyncResponse())
+	_, err = ParseQuickAdd(client, "task ^random", now, shanghai)
+	if assert.NotNil(err) {
+		assert.True(errors.Is(err, ErrProjectNotFound))
+		assert.Contains(err.Error(), "random")
+	}
+
+	// a project created elsewhere is found by a sync
+	syncResponse := BuildSyncResponse()
+	syncResponse.ProjectProfiles = append(syncResponse.ProjectProfiles, TestProjectItem{Name: "Errands", Id: "pid4"})
+	NewSyncTestServer(syncResponse)
+	task, err = ParseQuickAdd(client, "buy milk ^errands", now, shanghai)
+	assert.Nil(err)
+	assert.Equal("pid4", task.ProjectId)
+	assert.Equal("buy milk", task.Title)
+	assert.True(gock.IsDone())
+
+	// nothing left for the title
+	_, err = ParseQuickAdd(client, "#only !high tomorrow", now, shanghai)
+	assert.ErrorContains(err, "title")
+}
+
+func TestParseQuickAddSection(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildSampleClient()
+	now := time.Date(2023, 1, 4, 10, 0, 0, 0, time.UTC)
+	sections := map[string][]Section{
+		"pid1":        {{Id: "s1", ProjectId: "pid1", Name: "To Do"}, {Id: "s2", ProjectId: "pid1", Name: "Backlog"}},
+		"testinboxid": {{Id: "s3", ProjectId: "testinboxid", Name: "to do"}},
+	}
+
+	testCases := []struct {
+		input    string
+		title    string
+		columnId string
+		project  string // the project whose sections are fetched, none if empty
+	}{
+		{input: "write spec ~backlog ^pname1", title: "write spec", columnId: "s2", project: "pid1"},
+		{input: "~to do write spec tomorrow ^pname1", title: "write spec", columnId: "s1", project: "pid1"},
+		{input: "file taxes ~To Do", title: "file taxes", columnId: "s3", project: "testinboxid"},
+		{input: "buy ~5 apples", title: "buy ~5 apples"},
+		{input: "write spec ~later ^pname1", project: "pid1"},
+	}
+	for _, tc := range testCases {
+		if tc.project != "" {
+			gock.New(baseUrlV2Test).
+				Get(fmt.Sprintf(sectionListUrlEndpoint, tc.project)).
+				MatchHeader("Cookie", "t=testtoken").
+				Reply(200).
+				JSON(sections[tc.project])
+		}
+		task, err := ParseQuickAdd(client, tc.input, now, time.UTC)
+		assert.True(gock.IsDone(), tc.input)
+		if tc.title == "" {
+			assert.True(errors.Is(err, ErrSectionNotFound), tc.input)
+			assert.ErrorContains(err, "later in pname1", tc.input)
+			continue
+		}
+		if assert.Nil(err, tc.input) {
+			assert.Equal(tc.title, task.Title, tc.input)
+			assert.Equal(tc.columnId, task.ColumnId, tc.input)
+		}
+	}
+}
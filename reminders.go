@@ -0,0 +1,233 @@
+package ticktick
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	triggerPrefix         = "TRIGGER:"
+	triggerAbsolutePrefix = "TRIGGER;VALUE=DATE-TIME:"
+)
+
+// Reminder is a reminder of a task, relative to its due date (or its start date), or at
+// an absolute time. It is sent as the iCal TRIGGER, like "TRIGGER:-PT15M".
+// A trigger of the server that can not be parsed is kept as it is, see Parsed.
+type Reminder struct {
+	Id       string
+	Trigger  time.Duration // before the due date if negative, unused if Absolute is set
+	Absolute *time.Time
+
+	raw  string // the trigger as it was received, when it can not be parsed
+	bare bool   // received as a bare trigger string, sent back the same way
+}
+
+// Whether Trigger and Absolute are the reminder, false when the server sent a trigger that
+// can not be parsed, which is then sent back as it is
+func (r Reminder) Parsed() bool {
+	return r.raw == ""
+}
+
+// parse a reminder trigger, with or without the TRIGGER: prefix,
+// like "TRIGGER:-PT15M", "-P1DT15H" or "TRIGGER;VALUE=DATE-TIME:20230105T090000Z"
+func ParseReminder(trigger string) (Reminder, error) {
+	trigger = strings.TrimSpace(trigger)
+	if value, ok := strings.CutPrefix(trigger, triggerAbsolutePrefix); ok {
+		t, err := time.Parse(ruleTimeLayout, value)
+		if err != nil {
+			return Reminder{}, fmt.Errorf("%w: %q", ErrInvalidReminder, trigger)
+		}
+		return Reminder{Absolute: &t}, nil
+	}
+	d, err := parseTriggerDuration(strings.TrimPrefix(trigger, triggerPrefix))
+	if err != nil {
+		return Reminder{}, fmt.Errorf("%w: %q", ErrInvalidReminder, trigger)
+	}
+	return Reminder{Trigger: d}, nil
+}
+
+// an RFC 5545 duration, like -PT15M, P1W or -P1DT15H0M0S
+func parseTriggerDuration(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		sign, s = -1, rest
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	s, ok := strings.CutPrefix(s, "P")
+	if !ok || s == "" {
+		return 0, fmt.Errorf("invalid duration")
+	}
+	var d time.Duration
+	inTime, parts := false, 0
+	units := map[bool]map[byte]time.Duration{
+		false: {'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour},
+		true:  {'H': time.Hour, 'M': time.Minute, 'S': time.Second},
+	}
+	for s != "" {
+		if s[0] == 'T' && !inTime {
+			inTime, s = true, s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, fmt.Errorf("invalid duration")
+		}
+		n, err := strconv.Atoi(s[:i])
+		unit, ok := units[inTime][s[i]]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("invalid duration")
+		}
+		d += time.Duration(n) * unit
+		s, parts = s[i+1:], parts+1
+	}
+	if parts == 0 {
+		return 0, fmt.Errorf("invalid duration")
+	}
+	return sign * d, nil
+}
+
+// the reminder in the TRIGGER format
+func (r Reminder) String() string {
+	if r.raw != "" {
+		return r.raw
+	}
+	if r.Absolute != nil {
+		return triggerAbsolutePrefix + r.Absolute.UTC().Format(ruleTimeLayout)
+	}
+	return triggerPrefix + formatTriggerDuration(r.Trigger)
+}
+
+func formatTriggerDuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	if d < 0 {
+		b.WriteString("-")
+		d = -d
+	}
+	b.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		for _, unit := range []struct {
+			d    time.Duration
+			name string
+		}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+			if n := d / unit.d; n > 0 {
+				fmt.Fprintf(&b, "%d%s", n, unit.name)
+				d -= n * unit.d
+			}
+		}
+	}
+	return b.String()
+}
+
+// the reminders are objects like {"id": "...", "trigger": "TRIGGER:-PT15M"},
+// older tasks have the bare trigger strings, which are kept in their form
+type reminderJSON struct {
+	Id      string `json:"id"`
+	Trigger string `json:"trigger"`
+}
+
+func (r Reminder) MarshalJSON() ([]byte, error) {
+	if r.bare {
+		return json.Marshal(r.String())
+	}
+	return json.Marshal(reminderJSON{Id: r.Id, Trigger: r.String()})
+}
+
+func (r *Reminder) UnmarshalJSON(data []byte) error {
+	var raw reminderJSON
+	bare := bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`))
+	if bare {
+		if err := json.Unmarshal(data, &raw.Trigger); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	parsed, err := ParseReminder(raw.Trigger)
+	if err != nil {
+		// like "TRIGGER:-PT15M30.5S", a task should not be lost for one of its reminders
+		parsed = Reminder{raw: raw.Trigger}
+	}
+	parsed.Id = raw.Id
+	parsed.bare = bare
+	*r = parsed
+	return nil
+}
+
+// Add a reminder to the task, an id is generated if it has none. Call UpdateTask to save it.
+// "30 minutes before due" is Reminder{Trigger: -30 * time.Minute}.
+func (t *TaskItem) AddReminder(r Reminder) (*Reminder, error) {
+	if r.Id == "" {
+		r.Id = newObjectId()
+	}
+	for _, other := range t.Reminders {
+		if other.String() == r.String() {
+			return nil, fmt.Errorf("%w: duplicated %v", ErrInvalidReminder, r)
+		}
+	}
+	if err := t.checkReminder(r); err != nil {
+		return nil, err
+	}
+	t.Reminders = append(t.Reminders, r)
+	return &t.Reminders[len(t.Reminders)-1], nil
+}
+
+// Remove all the reminders of the task, call UpdateTask to save it
+func (t *TaskItem) ClearReminders() {
+	t.Reminders = []Reminder{}
+}
+
+// Check the reminders against what the server accepts: relative reminders need a date,
+// timed tasks remind at or before the date, all day tasks at the latest on their day,
+// the triggers are whole minutes and there are no duplicates.
+func (t *TaskItem) ValidateReminders() error {
+	for i, r := range t.Reminders {
+		for _, other := range t.Reminders[:i] {
+			if other.String() == r.String() {
+				return fmt.Errorf("%w: duplicated %v", ErrInvalidReminder, r)
+			}
+		}
+		if err := t.checkReminder(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TaskItem) checkReminder(r Reminder) error {
+	if !r.Parsed() {
+		return fmt.Errorf("%w: %q can not be parsed", ErrInvalidReminder, r.raw)
+	}
+	if r.Absolute != nil {
+		if r.Absolute.IsZero() {
+			return fmt.Errorf("%w: zero absolute time", ErrInvalidReminder)
+		}
+		return nil
+	}
+	switch {
+	case t.DueDate.IsZero() && t.StartDate.IsZero():
+		return fmt.Errorf("%w: %v on a task without date", ErrInvalidReminder, r)
+	case r.Trigger%time.Minute != 0:
+		return fmt.Errorf("%w: %v is not in whole minutes", ErrInvalidReminder, r)
+	case !t.IsAllDay && r.Trigger > 0:
+		return fmt.Errorf("%w: %v is after the date", ErrInvalidReminder, r)
+	case t.IsAllDay && r.Trigger >= 24*time.Hour:
+		return fmt.Errorf("%w: %v is after the day", ErrInvalidReminder, r)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package ticktick
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReminder(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		trigger string
+		want    time.Duration
+		format  string
+	}{
+		{"TRIGGER:-PT15M", -15 * time.Minute, "TRIGGER:-PT15M"},
+		{"TRIGGER:PT0S", 0, "TRIGGER:PT0S"},
+		{"TRIGGER:P0DT9H0M0S", 9 * time.Hour, "TRIGGER:PT9H"},
+		{"TRIGGER:-P1DT15H0M0S", -39 * time.Hour, "TRIGGER:-P1DT15H"},
+		{"-P1W", -7 * 24 * time.Hour, "TRIGGER:-P7D"},
+		{"+PT1H30M", 90 * time.Minute, "TRIGGER:PT1H30M"},
+	}
+	for _, tc := range testCases {
+		r, err := ParseReminder(tc.trigger)
+		assert.Nil(err, tc.trigger)
+		assert.Equal(tc.want, r.Trigger, tc.trigger)
+		assert.Nil(r.Absolute)
+		assert.Equal(tc.format, r.String())
+	}
+
+	// absolute reminders
+	r, err := ParseReminder("TRIGGER;VALUE=DATE-TIME:20230105T090000Z")
+	assert.Nil(err)
+	assert.Equal(time.Date(2023, 1, 5, 9, 0, 0, 0, time.UTC), *r.Absolute)
+	assert.Equal("TRIGGER;VALUE=DATE-TIME:20230105T090000Z", r.String())
+
+	// invalid reminders
+	for _, trigger := range []string{"", "TRIGGER:", "TRIGGER:-15M", "TRIGGER:PT", "TRIGGER:P1H", "TRIGGER:PT1D", "TRIGGER:PTM", "TRIGGER;VALUE=DATE-TIME:tomorrow"} {
+		_, err := ParseReminder(trigger)
+		assert.True(errors.Is(err, ErrInvalidReminder), trigger)
+	}
+}
+
+func TestReminderJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	// the server sends objects, older tasks have strings
+	var task TaskItem
+	err := json.Unmarshal([]byte(`{"reminders": [{"id": "r1", "trigger": "TRIGGER:-PT30M"}, "TRIGGER:PT0S"]}`), &task)
+	assert.Nil(err)
+	assert.Equal([]Reminder{{Id: "r1", Trigger: -30 * time.Minute}, {bare: true}}, task.Reminders)
+
+	// and they are sent back in the form they came in
+	data, err := json.Marshal(task.Reminders)
+	assert.Nil(err)
+	assert.JSONEq(`[{"id": "r1", "trigger": "TRIGGER:-PT30M"}, "TRIGGER:PT0S"]`, string(data))
+	data, err = json.Marshal(task)
+	assert.Nil(err)
+	assert.Contains(string(data), `"reminders":[{"id":"r1","trigger":"TRIGGER:-PT30M"},"TRIGGER:PT0S"]`)
+	data, err = json.Marshal([]Reminder{{Trigger: time.Minute}})
+	assert.Nil(err)
+	assert.JSONEq(`[{"id": "", "trigger": "TRIGGER:PT1M"}]`, string(data))
+
+	// the triggers that can not be parsed are kept as they are
+	task = TaskItem{}
+	err = json.Unmarshal([]byte(`{"reminders": ["TRIGGER:soon", {"id": "r2", "trigger": "TRIGGER:-PT15M30.5S"}]}`), &task)
+	assert.Nil(err)
+	if assert.Len(task.Reminders, 2) {
+		assert.False(task.Reminders[0].Parsed())
+		assert.Equal("TRIGGER:soon", task.Reminders[0].String())
+		assert.Equal("r2", task.Reminders[1].Id)
+		assert.True(errors.Is(task.ValidateReminders(), ErrInvalidReminder))
+	}
+	data, err = json.Marshal(task.Reminders)
+	assert.Nil(err)
+	assert.JSONEq(`["TRIGGER:soon", {"id": "r2", "trigger": "TRIGGER:-PT15M30.5S"}]`, string(data))
+	assert.True(Reminder{Trigger: time.Minute}.Parsed())
+
+	// invalid reminders
+	assert.NotNil(json.Unmarshal([]byte(`{"reminders": [12]}`), &task))
+}
+
+func TestAddReminder(t *testing.T) {
+	assert := assert.New(t)
+
+	// normal case
+	task := TaskItem{}
+	task.SetDue(time.Date(2023, 1, 5, 10, 0, 0, 0, time.UTC), false)
+	r, err := task.AddReminder(Reminder{Trigger: -30 * time.Minute})
+	assert.Nil(err)
+	assert.Len(r.Id, 24)
+	assert.Equal(-30*time.Minute, r.Trigger)
+	at := time.Date(2023, 1, 5, 8, 0, 0, 0, time.UTC)
+	_, err = task.AddReminder(Reminder{Id: "r2", Absolute: &at})
+	assert.Nil(err)
+	assert.Len(task.Reminders, 2)
+	assert.Nil(task.ValidateReminders())
+
+	// rejected reminders
+	_, err = task.AddReminder(Reminder{Trigger: -30 * time.Minute})
+	assert.True(errors.Is(err, ErrInvalidReminder))
+	_, err = task.AddReminder(Reminder{Trigger: time.Minute})
+	assert.True(errors.Is(err, ErrInvalidReminder))
+	_, err = task.AddReminder(Reminder{Trigger: -90 * time.Second})
+	assert.True(errors.Is(err, ErrInvalidReminder))
+	_, err = task.AddReminder(Reminder{Absolute: &time.Time{}})
+	assert.True(errors.Is(err, ErrInvalidReminder))
+	_, err = (&TaskItem{}).AddReminder(Reminder{})
+	assert.True(errors.Is(err, ErrInvalidReminder))
+	assert.Len(task.Reminders, 2)
+
+	// all day tasks remind on their day
+	allDay := TaskItem{}
+	allDay.SetDue(time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), true)
+	_, err = allDay.AddReminder(Reminder{Trigger: 9 * time.Hour})
+	assert.Nil(err)
+	_, err = allDay.AddReminder(Reminder{Trigger: 24 * time.Hour})
+	assert.True(errors.Is(err, ErrInvalidReminder))
+
+	// validation of the reminders from the server
+	task.Reminders = append(task.Reminders, Reminder{Id: "r3", Trigger: -30 * time.Minute})
+	assert.True(errors.Is(task.ValidateReminders(), ErrInvalidReminder))
+
+	// clear
+	task.ClearReminders()
+	assert.NotNil(task.Reminders)
+	assert.Empty(task.Reminders)
+	data, _ := json.Marshal(task)
+	assert.Contains(string(data), `"reminders":[]`)
+}
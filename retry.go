@@ -0,0 +1,183 @@
+package ticktick
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+)
+
+// RetryPolicy retries the idempotent requests, such as Sync and UpdateTask, when the
+// server answers 429 or 5xx, or when the connection fails. The zero value never retries.
+// The sign in is retried at most once, after the Retry-After of the server, as repeated
+// sign in attempts get the account flagged.
+type RetryPolicy struct {
+	MaxAttempts int           // the attempts in total, including the first one
+	BaseDelay   time.Duration // the delay before the first retry, doubled before each next one
+	MaxDelay    time.Duration // the cap of the delay, a longer Retry-After stops the retries
+	Jitter      float64       // randomize each delay by up to this fraction, from 0 to 1
+}
+
+// a reasonable policy for the batch jobs
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.2,
+}
+
+// retry the idempotent requests with the policy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		if policy.MaxAttempts < 0 || policy.BaseDelay < 0 || policy.MaxDelay < 0 || policy.Jitter < 0 || policy.Jitter > 1 {
+			return errors.New("invalid retry policy")
+		}
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+// send at most requestsPerSecond requests on average, with bursts of up to burst requests
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Client) error {
+		if requestsPerSecond <= 0 || burst < 1 {
+			return errors.New("invalid rate limit")
+		}
+		c.limiter = newRateLimiter(requestsPerSecond, burst)
+		return nil
+	}
+}
+
+// the delay before the given retry, starting from 1. ok is false if we should not retry.
+func (p RetryPolicy) delay(retry int, err error) (time.Duration, bool) {
+	if retry >= p.MaxAttempts || !isRetryable(err) {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	d := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(d), true
+}
+
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return errors.Is(err, requests.ErrTransport) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// call f until it succeeds, fails for good or runs out of attempts
+func (c *Client) retry(ctx context.Context, f func() error) error {
+	for retry := 1; ; retry++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		delay, ok := c.retryPolicy.delay(retry, err)
+		if !ok {
+			return err
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// call the sign in f, again only once and only when the server tells after how long
+func (c *Client) retrySignIn(ctx context.Context, f func() error) error {
+	err := f()
+	var apiErr *APIError
+	if err == nil || c.retryPolicy.MaxAttempts < 2 || !isRetryable(err) || !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+		return err
+	}
+	if c.retryPolicy.MaxDelay > 0 && apiErr.RetryAfter > c.retryPolicy.MaxDelay {
+		return err
+	}
+	if err := sleep(ctx, apiErr.RetryAfter); err != nil {
+		return err
+	}
+	return f()
+}
+
+// parse the Retry-After header, either in seconds or as a http date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// a token bucket shared by all the requests of a client
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take a token, waiting until one is available
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// reserve the token now, so that the waiting requests are served in order
+	l.tokens--
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		// give the reserved token back
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
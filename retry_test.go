@@ -0,0 +1,249 @@
+package ticktick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func BuildRetryClient(policy RetryPolicy) *Client {
+	return &Client{
+		UserName:       "testuser",
+		PassWord:       "testpass",
+		baseUrlV2:      baseUrlV2Test,
+		projectName2Id: make(map[string]string),
+		id2ProjectName: make(map[string]string),
+		loginToken:     "testtoken",
+		retryPolicy:    policy,
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	assert := assert.New(t)
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	// exponential backoff, capped
+	for retry, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond} {
+		d, ok := policy.delay(retry+1, &APIError{StatusCode: 503})
+		assert.True(ok)
+		assert.Equal(expected, d)
+	}
+	policy.MaxAttempts = 10
+	d, ok := policy.delay(6, &APIError{StatusCode: 500})
+	assert.True(ok)
+	assert.Equal(time.Second, d)
+
+	// out of attempts
+	_, ok = policy.delay(10, &APIError{StatusCode: 503})
+	assert.False(ok)
+
+	// Retry-After wins, unless it is longer than the max delay
+	d, ok = policy.delay(1, &APIError{StatusCode: 429, RetryAfter: 700 * time.Millisecond})
+	assert.True(ok)
+	assert.Equal(700*time.Millisecond, d)
+	_, ok = policy.delay(1, &APIError{StatusCode: 429, RetryAfter: time.Minute})
+	assert.False(ok)
+
+	// jitter
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		d, ok := policy.delay(1, &APIError{StatusCode: 503})
+		assert.True(ok)
+		assert.True(d >= 50*time.Millisecond && d <= 150*time.Millisecond)
+	}
+
+	// errors that are not worth retrying
+	_, ok = policy.delay(1, &APIError{StatusCode: 404})
+	assert.False(ok)
+	_, ok = policy.delay(1, fmt.Errorf("%w: %w", requests.ErrTransport, context.Canceled))
+	assert.False(ok)
+	_, ok = policy.delay(1, errors.New("random"))
+	assert.False(ok)
+	_, ok = policy.delay(1, fmt.Errorf("%w: connection reset", requests.ErrTransport))
+	assert.True(ok)
+
+	// the zero policy never retries
+	_, ok = RetryPolicy{}.delay(1, &APIError{StatusCode: 503})
+	assert.False(ok)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(3*time.Second, parseRetryAfter("3", now))
+	assert.Equal(10*time.Second, parseRetryAfter(now.Add(10*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(time.Duration(0), parseRetryAfter(now.Add(-10*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestRetry(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildRetryClient(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	// the sync is retried on 429 and 5xx
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(429)
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(503)
+	NewSyncTestServer(BuildSyncResponse())
+	assert.Nil(client.Sync())
+	assert.Len(client.tasks, 3)
+	assert.True(gock.IsDone())
+
+	// until the attempts run out
+	for i := 0; i < 3; i++ {
+		gock.New(baseUrlV2Test).
+			Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+			Reply(502)
+	}
+	err := client.Sync()
+	var apiErr *APIError
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(502, apiErr.StatusCode)
+	}
+	assert.True(gock.IsDone())
+
+	// the updates are idempotent
+	task := TaskItem{Id: "1", Title: "test"}
+	gock.New(baseUrlV2Test).
+		Post(fmt.Sprintf(taskUpdateUrlEndpoint, "1")).
+		Reply(500)
+	gock.New(baseUrlV2Test).
+		Post(fmt.Sprintf(taskUpdateUrlEndpoint, "1")).
+		Reply(200).
+		JSON(task)
+	_, err = client.UpdateTask(&task)
+	assert.Nil(err)
+	assert.True(gock.IsDone())
+
+	// while the creations are not
+	gock.New(baseUrlV2Test).
+		Post(taskCreateUrlEndpoint).
+		Reply(500)
+	gock.New(baseUrlV2Test).
+		Post(taskCreateUrlEndpoint).
+		Reply(200).
+		JSON(task)
+	_, err = client.CreateTask(&TaskItem{Title: "test"})
+	assert.NotNil(err)
+	assert.False(gock.IsDone())
+	gock.Flush()
+
+	// the sign in is not retried without Retry-After
+	for _, status := range []int{429, 503} {
+		gock.New(baseUrlV2Test).
+			Post(signinUrlEndpoint).
+			Reply(status)
+		gock.New(baseUrlV2Test).
+			Post(signinUrlEndpoint).
+			Reply(200).
+			JSON(map[string]string{"token": "newtoken"})
+		assert.NotNil(client.GetToken())
+		assert.False(gock.IsDone())
+		gock.Flush()
+	}
+
+	// and only once after it
+	for i := 0; i < 2; i++ {
+		gock.New(baseUrlV2Test).
+			Post(signinUrlEndpoint).
+			Reply(429).
+			SetHeader("Retry-After", "1")
+	}
+	gock.New(baseUrlV2Test).
+		Post(signinUrlEndpoint).
+		Reply(200).
+		JSON(map[string]string{"token": "newtoken"})
+	err = client.GetToken()
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(429, apiErr.StatusCode)
+	}
+	assert.Equal(1, len(gock.Pending()))
+	gock.Flush()
+	gock.New(baseUrlV2Test).
+		Post(signinUrlEndpoint).
+		Reply(429).
+		SetHeader("Retry-After", "1")
+	gock.New(baseUrlV2Test).
+		Post(signinUrlEndpoint).
+		Reply(200).
+		JSON(map[string]string{"token": "newtoken"})
+	assert.Nil(client.GetToken())
+	assert.Equal("newtoken", client.loginToken)
+	assert.True(gock.IsDone())
+
+	// the retries stop with the context
+	client.retryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(503)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.SyncCtx(ctx)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRateLimit(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	// the burst goes through at once, then one token every 10ms
+	limiter := newRateLimiter(100, 2)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		assert.Nil(limiter.wait(ctx))
+	}
+	assert.True(time.Since(start) >= 35*time.Millisecond)
+
+	// waiting stops with the context and gives the token back
+	limiter = newRateLimiter(1, 1)
+	assert.Nil(limiter.wait(ctx))
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.True(errors.Is(limiter.wait(ctx), context.DeadlineExceeded))
+	assert.True(limiter.tokens > -1)
+
+	// invalid options
+	_, err := NewClientWithOptions(WithRateLimit(0, 1))
+	assert.NotNil(err)
+	_, err = NewClientWithOptions(WithRateLimit(1, 0))
+	assert.NotNil(err)
+	_, err = NewClientWithOptions(WithRetryPolicy(RetryPolicy{Jitter: 2}))
+	assert.NotNil(err)
+}
+
+func TestClientRateLimit(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+
+	NewSignInTestServer()
+	for i := 0; i < 4; i++ {
+		NewSyncTestServer(BuildSyncResponse())
+	}
+	start := time.Now()
+	client, err := NewClientWithOptions(
+		WithCredentials("testuser", "testpass"),
+		WithServer("test"),
+		WithRateLimit(50, 1),
+		WithRetryPolicy(DefaultRetryPolicy),
+	)
+	if assert.Nil(err) {
+		for i := 0; i < 3; i++ {
+			assert.Nil(client.Sync())
+		}
+	}
+	// 5 requests, the 4 after the first one wait 20ms each
+	assert.True(time.Since(start) >= 75*time.Millisecond)
+	assert.True(gock.IsDone())
+}
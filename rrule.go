@@ -0,0 +1,615 @@
+package ticktick
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a repeat rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// RepeatFrom is the date the next occurrence of a repeating task is computed from
+type RepeatFrom string
+
+const (
+	RepeatFromDueDate       RepeatFrom = "0"
+	RepeatFromCompletedTime RepeatFrom = "1"
+)
+
+// the server sends a string, some tasks have a number
+func (f *RepeatFrom) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*f = RepeatFrom(n.String())
+		return nil
+	}
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*f = ""
+	if s != nil {
+		*f = RepeatFrom(*s)
+	}
+	return nil
+}
+
+// the TT_SKIP values
+const (
+	SkipHoliday = "HOLIDAY"
+	SkipWeekend = "WEEKEND"
+)
+
+const (
+	rrulePrefix     = "RRULE:"
+	erulePrefix     = "ERULE:"
+	ruleDateLayout  = "20060102"
+	ruleTimeLayout  = "20060102T150405Z"
+	ruleLocalLayout = "20060102T150405"
+)
+
+var ruleWeekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// RRuleDay is a BYDAY value, like MO, 1MO or -1FR.
+// N is the nth weekday of the month or the year, 0 for every one.
+type RRuleDay struct {
+	N       int
+	Weekday time.Weekday
+}
+
+func (d RRuleDay) String() string {
+	if d.N == 0 {
+		return ruleWeekdays[d.Weekday]
+	}
+	return strconv.Itoa(d.N) + ruleWeekdays[d.Weekday]
+}
+
+// RRule is a repeat rule of a task, the RFC 5545 RRULE with the extensions of TickTick.
+// Parse it from TaskItem.Repeat with ParseRRule, write it back with String.
+type RRule struct {
+	Freq       Frequency
+	Interval   int // 0 is 1
+	Count      int // 0 is unlimited
+	Until      time.Time
+	ByDay      []RRuleDay
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	WeekStart  *time.Weekday // WKST, nil for MO
+
+	// TickTick extensions
+	Skip    []string    // TT_SKIP, SkipHoliday and SkipWeekend occurrences are left out
+	Workday int         // TT_WORKDAY of a MONTHLY rule, the nth workday of the month, -1 for the last
+	Lunar   bool        // RSCALE=CHINESE, the dates are in the Chinese lunar calendar
+	Dates   []time.Time // the custom dates of "ERULE:NAME=CUSTOM;BYDATE=...", the other fields are unused
+
+	untilIsDate bool     // UNTIL was a date, it includes the whole day
+	unknown     []string // the parts we do not model, kept as they are
+}
+
+// Parse a repeat rule, with or without the RRULE: prefix. ERULE: rules have the custom dates.
+func ParseRRule(s string) (*RRule, error) {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, erulePrefix); ok {
+		return parseERule(rest)
+	}
+	s = strings.TrimPrefix(s, rrulePrefix)
+	r := &RRule{}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRepeat, part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported frequency")
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, r.untilIsDate, err = parseRuleTime(value)
+		case "BYDAY":
+			r.ByDay, err = parseRuleDays(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseRuleInts(value, -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseRuleInts(value, 1, 12)
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "BYSETPOS":
+			r.BySetPos, err = parseRuleInts(value, -366, 366)
+		case "WKST":
+			var days []RRuleDay
+			if days, err = parseRuleDays(value); err == nil && (len(days) != 1 || days[0].N != 0) {
+				err = fmt.Errorf("not a weekday")
+			}
+			if err == nil {
+				r.WeekStart = &days[0].Weekday
+			}
+		case "TT_SKIP":
+			r.Skip = strings.Split(strings.ToUpper(value), ",")
+		case "TT_WORKDAY":
+			r.Workday, err = strconv.Atoi(value)
+		case "RSCALE":
+			r.Lunar = strings.EqualFold(value, "CHINESE")
+			if !r.Lunar {
+				err = fmt.Errorf("unsupported calendar")
+			}
+		default:
+			r.unknown = append(r.unknown, part)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidRepeat, part, err)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: %q has no FREQ", ErrInvalidRepeat, s)
+	}
+	return r, nil
+}
+
+func parseERule(s string) (*RRule, error) {
+	r := &RRule{}
+	for _, part := range strings.Split(s, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(key) {
+		case "BYDATE":
+			for _, d := range strings.Split(value, ",") {
+				date, _, err := parseRuleTime(d)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %q: %v", ErrInvalidRepeat, d, err)
+				}
+				r.Dates = append(r.Dates, date)
+			}
+		case "NAME", "":
+		default:
+			r.unknown = append(r.unknown, part)
+		}
+	}
+	if len(r.Dates) == 0 {
+		return nil, fmt.Errorf("%w: %q has no BYDATE", ErrInvalidRepeat, s)
+	}
+	return r, nil
+}
+
+// a date, a UTC time or a floating time, taken as UTC
+func parseRuleTime(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(ruleDateLayout, s); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(ruleTimeLayout, s); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(ruleLocalLayout, s)
+	return t, false, err
+}
+
+func parseRuleDays(s string) ([]RRuleDay, error) {
+	var days []RRuleDay
+	for _, v := range strings.Split(strings.ToUpper(s), ",") {
+		if len(v) < 2 {
+			return nil, fmt.Errorf("invalid day %q", v)
+		}
+		day := RRuleDay{Weekday: -1}
+		for i, name := range ruleWeekdays {
+			if v[len(v)-2:] == name {
+				day.Weekday = time.Weekday(i)
+			}
+		}
+		if day.Weekday < 0 {
+			return nil, fmt.Errorf("invalid day %q", v)
+		}
+		if n := v[:len(v)-2]; n != "" {
+			var err error
+			if day.N, err = strconv.Atoi(n); err != nil || day.N == 0 || day.N < -53 || day.N > 53 {
+				return nil, fmt.Errorf("invalid day %q", v)
+			}
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parseRuleInts(s string, min, max int) ([]int, error) {
+	var values []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %q", v)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// the rule in the format of TaskItem.Repeat, like "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=MO"
+func (r *RRule) String() string {
+	if len(r.Dates) > 0 {
+		dates := make([]string, len(r.Dates))
+		for i, d := range r.Dates {
+			dates[i] = d.Format(ruleDateLayout)
+		}
+		return erulePrefix + strings.Join(append([]string{"NAME=CUSTOM", "BYDATE=" + strings.Join(dates, ",")}, r.unknown...), ";")
+	}
+
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	parts := []string{"FREQ=" + string(r.Freq), "INTERVAL=" + strconv.Itoa(interval)}
+	if r.Lunar {
+		parts = append(parts, "RSCALE=CHINESE")
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.untilIsDate {
+			parts = append(parts, "UNTIL="+r.Until.Format(ruleDateLayout))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format(ruleTimeLayout))
+		}
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = int(m)
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != nil {
+		parts = append(parts, "WKST="+ruleWeekdays[*r.WeekStart])
+	}
+	if r.Workday != 0 {
+		parts = append(parts, "TT_WORKDAY="+strconv.Itoa(r.Workday))
+	}
+	if len(r.Skip) > 0 {
+		parts = append(parts, "TT_SKIP="+strings.Join(r.Skip, ","))
+	}
+	return rrulePrefix + strings.Join(append(parts, r.unknown...), ";")
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+// The repeat rule of the task, nil if it does not repeat
+func (t *TaskItem) RepeatRule() (*RRule, error) {
+	if t.Repeat == "" {
+		return nil, nil
+	}
+	return ParseRRule(t.Repeat)
+}
+
+// Set the repeat rule of the task, a nil rule stops the repeat. Call UpdateTask to save it.
+func (t *TaskItem) SetRepeat(rule *RRule, from RepeatFrom) {
+	if rule == nil {
+		t.Repeat = ""
+		t.RepeatFrom = ""
+		return
+	}
+	t.Repeat = rule.String()
+	t.RepeatFrom = from
+}
+
+// The start times of the occurrences of the task in [from, to), in the location of the task.
+// The first occurrence is the start date, or the due date if the task has no start date.
+// A task that does not repeat has at most one occurrence.
+//
+// The tasks repeating from the completed time are expanded as if every occurrence was completed
+// on time. Holidays are not known locally, TT_SKIP=HOLIDAY only skips the weekends.
+// Lunar rules return ErrUnsupportedRepeat.
+func Occurrences(t TaskItem, from, to time.Time) ([]time.Time, error) {
+	loc := t.Location()
+	if loc == nil {
+		loc = time.Local
+	}
+	start := t.StartIn(loc)
+	if start.IsZero() {
+		start = t.DueIn(loc)
+	}
+	if start.IsZero() {
+		return nil, nil
+	}
+	rule, err := t.RepeatRule()
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		if start.Before(from) || !start.Before(to) {
+			return nil, nil
+		}
+		return []time.Time{start}, nil
+	}
+	return rule.Between(start, from, to)
+}
+
+// The occurrences of the rule starting at dtstart, in [from, to).
+// The occurrences keep the clock and the location of dtstart.
+func (r *RRule) Between(dtstart, from, to time.Time) ([]time.Time, error) {
+	if r.Lunar {
+		return nil, fmt.Errorf("%w: lunar calendar", ErrUnsupportedRepeat)
+	}
+	var result []time.Time
+	if len(r.Dates) > 0 {
+		for _, d := range r.Dates {
+			t := onDay(d.Year(), d.Month(), d.Day(), dtstart)
+			if !t.Before(from) && t.Before(to) {
+				result = append(result, t)
+			}
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+		return result, nil
+	}
+
+	until := r.Until
+	if r.untilIsDate {
+		until = time.Date(until.Year(), until.Month(), until.Day()+1, 0, 0, 0, 0, dtstart.Location()).Add(-time.Nanosecond)
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	count := 0
+	for period := 0; ; period++ {
+		// every occurrence of the period is past to or until when its first day is
+		if start := r.periodStart(dtstart, period*interval); start.After(to) || (!until.IsZero() && start.After(until)) {
+			return result, nil
+		}
+		for _, t := range r.expand(dtstart, period*interval) {
+			if t.Before(dtstart) || r.skipped(t) {
+				continue
+			}
+			if (!until.IsZero() && t.After(until)) || !t.Before(to) {
+				return result, nil
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return result, nil
+			}
+			if !t.Before(from) {
+				result = append(result, t)
+			}
+		}
+	}
+}
+
+func (r *RRule) skipped(t time.Time) bool {
+	if Contains(r.Skip, SkipWeekend) || Contains(r.Skip, SkipHoliday) {
+		return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+	}
+	return false
+}
+
+// the same clock as dtstart on a day, in the location of dtstart
+func onDay(year int, month time.Month, day int, dtstart time.Time) time.Time {
+	return time.Date(year, month, day, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location())
+}
+
+// the first day of the nth period after the one of dtstart
+func (r *RRule) periodStart(dtstart time.Time, n int) time.Time {
+	y, m, d := dtstart.Date()
+	switch r.Freq {
+	case Weekly:
+		weekStart := time.Monday
+		if r.WeekStart != nil {
+			weekStart = *r.WeekStart
+		}
+		offset := (int(dtstart.Weekday()) - int(weekStart) + 7) % 7
+		return onDay(y, m, d-offset+7*n, dtstart)
+	case Monthly:
+		return onDay(y, m+time.Month(n), 1, dtstart)
+	case Yearly:
+		return onDay(y+n, time.January, 1, dtstart)
+	}
+	return onDay(y, m, d+n, dtstart)
+}
+
+// the sorted occurrences in the nth period, before the COUNT and UNTIL limits
+func (r *RRule) expand(dtstart time.Time, n int) []time.Time {
+	start := r.periodStart(dtstart, n)
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		if r.matchMonth(start) && r.matchMonthDay(start) && r.matchWeekday(start) {
+			days = append(days, start)
+		}
+	case Weekly:
+		for i := 0; i < 7; i++ {
+			day := onDay(start.Year(), start.Month(), start.Day()+i, dtstart)
+			if len(r.ByDay) == 0 && day.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if r.matchMonth(day) && r.matchWeekday(day) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		if r.matchMonth(start) {
+			days = r.expandMonth(start, dtstart)
+		}
+	case Yearly:
+		switch {
+		case len(r.ByMonth) > 0 || len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				month := onDay(start.Year(), m, 1, dtstart)
+				if r.matchMonth(month) {
+					days = append(days, r.expandMonth(month, dtstart)...)
+				}
+			}
+		case len(r.ByDay) > 0:
+			end := onDay(start.Year()+1, time.January, 1, dtstart)
+			days = r.byDayIn(start, end, dtstart)
+		default:
+			if day := onDay(start.Year(), dtstart.Month(), dtstart.Day(), dtstart); day.Month() == dtstart.Month() {
+				days = append(days, day)
+			}
+		}
+	}
+	return r.applySetPos(days)
+}
+
+// the days of the month starting at month, for a MONTHLY rule or a month of a YEARLY rule
+func (r *RRule) expandMonth(month, dtstart time.Time) []time.Time {
+	end := onDay(month.Year(), month.Month()+1, 1, dtstart)
+	if r.Workday != 0 {
+		var workdays []time.Time
+		for day := month; day.Before(end); day = onDay(day.Year(), day.Month(), day.Day()+1, dtstart) {
+			if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+				workdays = append(workdays, day)
+			}
+		}
+		if i := nth(r.Workday, len(workdays)); i >= 0 {
+			return []time.Time{workdays[i]}
+		}
+		return nil
+	}
+
+	var days []time.Time
+	switch {
+	case len(r.ByDay) > 0:
+		for _, day := range r.byDayIn(month, end, dtstart) {
+			if r.matchMonthDay(day) {
+				days = append(days, day)
+			}
+		}
+	case len(r.ByMonthDay) > 0:
+		for day := month; day.Before(end); day = onDay(day.Year(), day.Month(), day.Day()+1, dtstart) {
+			if r.matchMonthDay(day) {
+				days = append(days, day)
+			}
+		}
+	default:
+		// the day of dtstart, the months without it are skipped
+		if day := onDay(month.Year(), month.Month(), dtstart.Day(), dtstart); day.Month() == month.Month() {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// the days in [start, end) matching BYDAY, where the ordinals count in [start, end)
+func (r *RRule) byDayIn(start, end, dtstart time.Time) []time.Time {
+	byWeekday := make(map[time.Weekday][]time.Time)
+	for day := start; day.Before(end); day = onDay(day.Year(), day.Month(), day.Day()+1, dtstart) {
+		byWeekday[day.Weekday()] = append(byWeekday[day.Weekday()], day)
+	}
+	var days []time.Time
+	seen := make(map[time.Time]bool)
+	for _, d := range r.ByDay {
+		matches := byWeekday[d.Weekday]
+		if d.N != 0 {
+			i := nth(d.N, len(matches))
+			if i < 0 {
+				continue
+			}
+			matches = matches[i : i+1]
+		}
+		for _, day := range matches {
+			if !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// the index of the nth item, counting from the end if n is negative, -1 if out of range
+func nth(n, length int) int {
+	i := n - 1
+	if n < 0 {
+		i = length + n
+	}
+	if i < 0 || i >= length {
+		return -1
+	}
+	return i
+}
+
+func (r *RRule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 {
+		return days
+	}
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		if i := nth(pos, len(days)); i >= 0 && !Contains(selected, days[i]) {
+			selected = append(selected, days[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	return selected
+}
+
+func (r *RRule) matchMonth(t time.Time) bool {
+	return len(r.ByMonth) == 0 || Contains(r.ByMonth, t.Month())
+}
+
+func (r *RRule) matchWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || (d < 0 && daysInMonth+d+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
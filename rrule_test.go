@@ -0,0 +1,208 @@
+package ticktick
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRRule(t *testing.T) {
+	assert := assert.New(t)
+
+	// normal case
+	r, err := ParseRRule("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,-1FR;COUNT=5;WKST=SU")
+	assert.Nil(err)
+	assert.Equal(Weekly, r.Freq)
+	assert.Equal(2, r.Interval)
+	assert.Equal(5, r.Count)
+	assert.Equal([]RRuleDay{{0, time.Monday}, {-1, time.Friday}}, r.ByDay)
+	assert.Equal(time.Sunday, *r.WeekStart)
+	assert.Equal("RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=5;BYDAY=MO,-1FR;WKST=SU", r.String())
+
+	// the TickTick extensions and the unknown parts round trip
+	for _, rule := range []string{
+		"RRULE:FREQ=DAILY;INTERVAL=1;TT_SKIP=HOLIDAY,WEEKEND",
+		"RRULE:FREQ=MONTHLY;INTERVAL=1;TT_WORKDAY=-1",
+		"RRULE:FREQ=YEARLY;INTERVAL=1;RSCALE=CHINESE;BYMONTH=8;BYMONTHDAY=15",
+		"RRULE:FREQ=MONTHLY;INTERVAL=1;UNTIL=20230301;BYMONTHDAY=-1",
+		"RRULE:FREQ=MONTHLY;INTERVAL=1;UNTIL=20230301T120000Z;BYDAY=2TU;BYSETPOS=1",
+		"RRULE:FREQ=DAILY;INTERVAL=3;TT_NEW=1",
+		"ERULE:NAME=CUSTOM;BYDATE=20230105,20230210",
+	} {
+		r, err := ParseRRule(rule)
+		if assert.Nil(err, rule) {
+			assert.Equal(rule, r.String())
+		}
+	}
+
+	// without the prefix
+	r, err = ParseRRule("FREQ=DAILY")
+	assert.Nil(err)
+	assert.Equal("RRULE:FREQ=DAILY;INTERVAL=1", r.String())
+
+	// the extensions
+	r, _ = ParseRRule("RRULE:FREQ=MONTHLY;TT_WORKDAY=2;TT_SKIP=weekend")
+	assert.Equal(2, r.Workday)
+	assert.Equal([]string{SkipWeekend}, r.Skip)
+	r, _ = ParseRRule("ERULE:NAME=CUSTOM;BYDATE=20230105")
+	assert.Equal([]time.Time{time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)}, r.Dates)
+
+	// invalid rules
+	for _, rule := range []string{
+		"",
+		"RRULE:INTERVAL=1",
+		"RRULE:FREQ=HOURLY",
+		"RRULE:FREQ=DAILY;INTERVAL=0",
+		"RRULE:FREQ=WEEKLY;BYDAY=XX",
+		"RRULE:FREQ=WEEKLY;BYDAY=0MO",
+		"RRULE:FREQ=MONTHLY;BYMONTHDAY=32",
+		"RRULE:FREQ=YEARLY;BYMONTH=13",
+		"RRULE:FREQ=DAILY;UNTIL=tomorrow",
+		"RRULE:FREQ=DAILY;WKST=1MO",
+		"RRULE:FREQ=DAILY;RSCALE=HEBREW",
+		"RRULE:FREQ=DAILY;BROKEN",
+		"ERULE:NAME=CUSTOM",
+		"ERULE:NAME=CUSTOM;BYDATE=2023",
+	} {
+		_, err := ParseRRule(rule)
+		assert.True(errors.Is(err, ErrInvalidRepeat), rule)
+	}
+}
+
+func TestRRuleBetween(t *testing.T) {
+	assert := assert.New(t)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	// a Sunday
+	dtstart := time.Date(2023, 1, 1, 9, 0, 0, 0, shanghai)
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, shanghai)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2023, month, d, 9, 0, 0, 0, shanghai)
+	}
+
+	testCases := []struct {
+		rule string
+		to   time.Time
+		want []time.Time
+	}{
+		{"FREQ=DAILY;INTERVAL=2", day(1, 8), []time.Time{day(1, 1), day(1, 3), day(1, 5), day(1, 7)}},
+		{"FREQ=DAILY;COUNT=2", day(2, 1), []time.Time{day(1, 1), day(1, 2)}},
+		{"FREQ=DAILY;UNTIL=20230103", day(2, 1), []time.Time{day(1, 1), day(1, 2), day(1, 3)}},
+		{"FREQ=DAILY;UNTIL=20230102T000000Z", day(2, 1), []time.Time{day(1, 1)}},
+		{"FREQ=DAILY;BYDAY=MO,WE", day(1, 9), []time.Time{day(1, 2), day(1, 4)}},
+		{"FREQ=DAILY;TT_SKIP=WEEKEND", day(1, 9), []time.Time{day(1, 2), day(1, 3), day(1, 4), day(1, 5), day(1, 6)}},
+		{"FREQ=WEEKLY", day(1, 16), []time.Time{day(1, 1), day(1, 8), day(1, 15)}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU", day(1, 23), []time.Time{day(1, 1), day(1, 9), day(1, 15)}},
+		{"FREQ=WEEKLY;BYDAY=SA;TT_SKIP=WEEKEND", day(3, 1), nil},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", day(6, 1), []time.Time{day(1, 31), day(3, 31), day(5, 31)}},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", day(2, 2), []time.Time{day(1, 1), day(1, 31), day(2, 1)}},
+		{"FREQ=MONTHLY;BYDAY=-1FR", day(3, 1), []time.Time{day(1, 27), day(2, 24)}},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", day(4, 1), []time.Time{day(1, 31), day(2, 28), day(3, 31)}},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", day(12, 31), []time.Time{day(1, 13), day(10, 13)}},
+		{"FREQ=MONTHLY;TT_WORKDAY=1", day(4, 1), []time.Time{day(1, 2), day(2, 1), day(3, 1)}},
+		{"FREQ=MONTHLY;TT_WORKDAY=-1", day(3, 1), []time.Time{day(1, 31), day(2, 28)}},
+		{"FREQ=MONTHLY;COUNT=3", day(12, 31), []time.Time{day(1, 1), day(2, 1), day(3, 1)}},
+		{"FREQ=YEARLY;COUNT=2", time.Date(2030, 1, 1, 0, 0, 0, 0, shanghai), []time.Time{day(1, 1), time.Date(2024, 1, 1, 9, 0, 0, 0, shanghai)}},
+		{"FREQ=YEARLY;BYMONTH=3,6", day(12, 31), []time.Time{day(3, 1), day(6, 1)}},
+		{"FREQ=YEARLY;BYMONTH=5;BYDAY=2SU", day(12, 31), []time.Time{day(5, 14)}},
+		{"FREQ=YEARLY;BYDAY=1MO", day(12, 31), []time.Time{day(1, 2)}},
+		{"FREQ=YEARLY;BYDAY=-1MO", day(12, 31), []time.Time{day(12, 25)}},
+		{"ERULE:NAME=CUSTOM;BYDATE=20230210,20230105,20240101", day(12, 31), []time.Time{day(1, 5), day(2, 10)}},
+	}
+	for _, tc := range testCases {
+		r, err := ParseRRule(tc.rule)
+		if !assert.Nil(err, tc.rule) {
+			continue
+		}
+		got, err := r.Between(dtstart, from, tc.to)
+		assert.Nil(err, tc.rule)
+		assert.Equal(tc.want, got, tc.rule)
+	}
+
+	// from limits the result, but the count starts at dtstart
+	r, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	got, _ := r.Between(dtstart, day(1, 2), day(2, 1))
+	assert.Equal([]time.Time{day(1, 2), day(1, 3)}, got)
+
+	// lunar rules
+	r, _ = ParseRRule("FREQ=YEARLY;RSCALE=CHINESE")
+	_, err := r.Between(dtstart, from, day(12, 31))
+	assert.True(errors.Is(err, ErrUnsupportedRepeat))
+}
+
+func TestOccurrences(t *testing.T) {
+	assert := assert.New(t)
+	newYork, _ := time.LoadLocation("America/New_York")
+	from := time.Date(2023, 3, 1, 0, 0, 0, 0, newYork)
+	to := time.Date(2023, 3, 31, 0, 0, 0, 0, newYork)
+
+	// keeps the clock across daylight saving time
+	task := TaskItem{Title: "standup"}
+	task.SetDue(time.Date(2023, 3, 10, 9, 30, 0, 0, newYork), false)
+	task.SetRepeat(&RRule{Freq: Weekly, ByDay: []RRuleDay{{Weekday: time.Friday}}}, RepeatFromDueDate)
+	assert.Equal("RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=FR", task.Repeat)
+	assert.Equal(RepeatFromDueDate, task.RepeatFrom)
+	got, err := Occurrences(task, from, to)
+	assert.Nil(err)
+	assert.Equal([]time.Time{
+		time.Date(2023, 3, 10, 9, 30, 0, 0, newYork),
+		time.Date(2023, 3, 17, 9, 30, 0, 0, newYork),
+		time.Date(2023, 3, 24, 9, 30, 0, 0, newYork),
+	}, got)
+
+	// all day tasks are expanded in their time zone
+	task = TaskItem{}
+	task.SetDue(time.Date(2023, 3, 30, 0, 0, 0, 0, newYork), true)
+	task.Repeat = "RRULE:FREQ=DAILY;INTERVAL=1"
+	got, _ = Occurrences(task, from, time.Date(2023, 4, 2, 0, 0, 0, 0, newYork))
+	assert.Equal([]time.Time{
+		time.Date(2023, 3, 30, 0, 0, 0, 0, newYork),
+		time.Date(2023, 3, 31, 0, 0, 0, 0, newYork),
+		time.Date(2023, 4, 1, 0, 0, 0, 0, newYork),
+	}, got)
+
+	// the rule is read back
+	rule, err := task.RepeatRule()
+	assert.Nil(err)
+	assert.Equal(Daily, rule.Freq)
+
+	// tasks that do not repeat
+	task.SetRepeat(nil, "")
+	assert.Equal("", task.Repeat)
+	rule, err = task.RepeatRule()
+	assert.Nil(rule)
+	assert.Nil(err)
+	got, _ = Occurrences(task, from, to)
+	assert.Equal([]time.Time{time.Date(2023, 3, 30, 0, 0, 0, 0, newYork)}, got)
+	got, _ = Occurrences(task, to, to.AddDate(0, 0, 1))
+	assert.Empty(got)
+	got, _ = Occurrences(TaskItem{Repeat: "RRULE:FREQ=DAILY"}, from, to)
+	assert.Empty(got)
+
+	// invalid rules
+	task.Repeat = "RRULE:FREQ=SOMETIMES"
+	_, err = Occurrences(task, from, to)
+	assert.True(errors.Is(err, ErrInvalidRepeat))
+}
+
+func TestRepeatFromJSON(t *testing.T) {
+	assert := assert.New(t)
+	testCases := []struct {
+		raw      string
+		expected RepeatFrom
+	}{
+		{raw: `"1"`, expected: RepeatFromCompletedTime},
+		{raw: `1`, expected: RepeatFromCompletedTime},
+		{raw: `0`, expected: RepeatFromDueDate},
+		{raw: `null`, expected: ""},
+	}
+	for _, tc := range testCases {
+		f := RepeatFrom("2")
+		assert.Nil(json.Unmarshal([]byte(tc.raw), &f), tc.raw)
+		assert.Equal(tc.expected, f, tc.raw)
+	}
+	var f RepeatFrom
+	assert.NotNil(json.Unmarshal([]byte(`true`), &f))
+}
@@ -0,0 +1,302 @@
+package ticktick
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/carlmjohnson/requests"
+)
+
+const (
+	tagBatchUrlEndpoint  = "/batch/tag"  // POST
+	tagRenameUrlEndpoint = "/tag/rename" // PUT
+	tagMergeUrlEndpoint  = "/tag/merge"  // PUT
+	tagDeleteUrlEndpoint = "/tag"        // DELETE
+)
+
+// Tag is identified by its lowercase Name, while Label keeps the case shown in the app.
+// A tag can be nested under a Parent tag, only one level deep.
+type Tag struct {
+	Name      string `json:"name"`
+	Label     string `json:"label"`
+	Color     string `json:"color,omitempty"`
+	Parent    string `json:"parent,omitempty"`
+	SortType  string `json:"sortType,omitempty"` // project, dueDate, title or priority
+	SortOrder int64  `json:"sortOrder"`
+}
+
+func tagName(label string) string {
+	return strings.ToLower(label)
+}
+
+// find a synced tag by name, c.mu must be held
+func (c *Client) findTag(name string) (int, bool) {
+	for i, t := range c.tags {
+		if t.Name == tagName(name) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// apply f to the synced tags and to the tag names of every synced task, c.mu must not be held.
+// f returns the new name of a tag, or "" to remove it. A tag renamed to the name of a tag
+// that stays is merged into it, which keeps its own color, order and parent, and the
+// children keep the nesting one level deep.
+func (c *Client) rewriteTags(f func(name string) string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	staying := make(map[string]bool)
+	for _, t := range c.tags {
+		if f(t.Name) == t.Name {
+			staying[t.Name] = true
+		}
+	}
+	var tags []Tag
+	seen := make(map[string]bool)
+	for _, t := range c.tags {
+		name := f(t.Name)
+		if name == "" || seen[name] || (name != t.Name && staying[name]) {
+			continue
+		}
+		seen[name] = true
+		if name != t.Name {
+			t.Name = name
+			t.Label = name
+		}
+		if t.Parent != "" {
+			t.Parent = f(t.Parent)
+		}
+		if t.Parent == t.Name {
+			t.Parent = ""
+		}
+		tags = append(tags, t)
+	}
+	parents := make(map[string]string)
+	for _, t := range tags {
+		parents[t.Name] = t.Parent
+	}
+	for i, t := range tags {
+		if grandParent := parents[t.Parent]; grandParent != "" {
+			tags[i].Parent = grandParent
+		}
+	}
+	c.tags = tags
+
+	// the snapshot is shared with the search results, so replace the slices instead of editing them
+	for i, task := range c.tasks {
+		var taskTags []string
+		changed := false
+		for _, name := range task.Tags {
+			newName := f(tagName(name))
+			if newName != tagName(name) {
+				changed = true
+			}
+			if newName != "" && !Contains(taskTags, newName) {
+				taskTags = append(taskTags, newName)
+			}
+		}
+		if changed {
+			c.tasks[i].Tags = taskTags
+		}
+	}
+}
+
+// CURD, Read, the synced tags
+func (c *Client) ListTags() ([]Tag, error) {
+	return c.ListTagsCtx(context.Background())
+}
+
+// ListTagsCtx is ListTags with a context
+func (c *Client) ListTagsCtx(ctx context.Context) ([]Tag, error) {
+	if err := c.SyncCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Tag(nil), c.tags...), nil
+}
+
+// CURD, Create. The name defaults to the lowercase label and the label to the name.
+func (c *Client) CreateTag(t *Tag) (*Tag, error) {
+	return c.CreateTagCtx(context.Background(), t)
+}
+
+// CreateTagCtx is CreateTag with a context
+func (c *Client) CreateTagCtx(ctx context.Context, t *Tag) (*Tag, error) {
+	newt := *t
+	if newt.Label == "" {
+		newt.Label = newt.Name
+	}
+	newt.Name = tagName(newt.Label)
+	if newt.Name == "" {
+		return nil, fmt.Errorf("tag name is empty")
+	}
+	c.mu.RLock()
+	_, exists := c.findTag(newt.Name)
+	c.mu.RUnlock()
+	if exists {
+		return nil, fmt.Errorf("tag %v already exists", newt.Name)
+	}
+	if err := c.checkTagParent(newt.Name, newt.Parent); err != nil {
+		return nil, err
+	}
+
+	if err := c.batchItem(ctx, tagBatchUrlEndpoint, "add", newt, newt.Name); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.tags = append(c.tags, newt)
+	c.mu.Unlock()
+	return &newt, nil
+}
+
+// the parent must be a synced top level tag, and a tag with children can not be nested
+func (c *Client) checkTagParent(name, parent string) error {
+	if parent == "" {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	i, ok := c.findTag(parent)
+	if !ok {
+		return fmt.Errorf("parent tag %v not found", parent)
+	}
+	if tagName(parent) == tagName(name) {
+		return fmt.Errorf("tag %v can not be its own parent", name)
+	}
+	if c.tags[i].Parent != "" {
+		return fmt.Errorf("parent tag %v is already nested, tags can only be nested one level deep", parent)
+	}
+	for _, t := range c.tags {
+		if t.Parent == tagName(name) {
+			return fmt.Errorf("tag %v has children, tags can only be nested one level deep", name)
+		}
+	}
+	return nil
+}
+
+// Nest the tag under the parent tag, an empty parent moves it back to the top level
+func (c *Client) SetTagParent(name, parent string) (*Tag, error) {
+	return c.SetTagParentCtx(context.Background(), name, parent)
+}
+
+// SetTagParentCtx is SetTagParent with a context
+func (c *Client) SetTagParentCtx(ctx context.Context, name, parent string) (*Tag, error) {
+	c.mu.RLock()
+	i, ok := c.findTag(name)
+	var newt Tag
+	if ok {
+		newt = c.tags[i]
+	}
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("tag %v not found", name)
+	}
+	if err := c.checkTagParent(name, parent); err != nil {
+		return nil, err
+	}
+	newt.Parent = tagName(parent)
+
+	if err := c.batchItem(ctx, tagBatchUrlEndpoint, "update", newt, newt.Name); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if i, ok := c.findTag(name); ok {
+		c.tags[i] = newt
+	}
+	c.mu.Unlock()
+	return &newt, nil
+}
+
+// Rename the tag, the server renames it on every task, and so do we on the synced tasks
+func (c *Client) RenameTag(name, newName string) error {
+	return c.RenameTagCtx(context.Background(), name, newName)
+}
+
+// RenameTagCtx is RenameTag with a context
+func (c *Client) RenameTagCtx(ctx context.Context, name, newName string) error {
+	if name == "" || newName == "" {
+		return fmt.Errorf("tag name is empty")
+	}
+	body := map[string]string{"name": tagName(name), "newName": newName}
+	if err := c.fetch(ctx, func() *requests.Builder {
+		return c.request(tagRenameUrlEndpoint).
+			Put().
+			BodyJSON(body)
+	}); err != nil {
+		return err
+	}
+
+	c.rewriteTags(func(n string) string {
+		if n == tagName(name) {
+			return tagName(newName)
+		}
+		return n
+	})
+	c.mu.Lock()
+	if i, ok := c.findTag(newName); ok {
+		c.tags[i].Label = newName
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// Merge the tag from into the tag to, the tasks tagged with from are tagged with to instead
+func (c *Client) MergeTags(from, to string) error {
+	return c.MergeTagsCtx(context.Background(), from, to)
+}
+
+// MergeTagsCtx is MergeTags with a context
+func (c *Client) MergeTagsCtx(ctx context.Context, from, to string) error {
+	if from == "" || to == "" {
+		return fmt.Errorf("tag name is empty")
+	}
+	body := map[string]string{"name": tagName(from), "newName": tagName(to)}
+	if err := c.fetch(ctx, func() *requests.Builder {
+		return c.request(tagMergeUrlEndpoint).
+			Put().
+			BodyJSON(body)
+	}); err != nil {
+		return err
+	}
+
+	c.rewriteTags(func(n string) string {
+		if n == tagName(from) {
+			return tagName(to)
+		}
+		return n
+	})
+	return nil
+}
+
+// CURD, Delete, the tag is removed from every task and its children move to the top level
+func (c *Client) DeleteTag(name string) error {
+	return c.DeleteTagCtx(context.Background(), name)
+}
+
+// DeleteTagCtx is DeleteTag with a context
+func (c *Client) DeleteTagCtx(ctx context.Context, name string) error {
+	if name == "" {
+		return fmt.Errorf("tag name is empty")
+	}
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(tagDeleteUrlEndpoint).
+			Delete().
+			Param("name", tagName(name))
+	}); err != nil {
+		return err
+	}
+
+	c.rewriteTags(func(n string) string {
+		if n == tagName(name) {
+			return ""
+		}
+		return n
+	})
+	return nil
+}
@@ -0,0 +1,291 @@
+package ticktick
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func tagNames(tags []Tag) []string {
+	var names []string
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func BuildTagClient() *Client {
+	NewSignInTestServer()
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200).
+		JSON(map[string]any{
+			"inboxId": "testinboxid",
+			"syncTaskBean": map[string]any{"update": []TaskItem{
+				{Id: "1", Title: "1", Tags: []string{"work", "urgent"}},
+				{Id: "2", Title: "2", Tags: []string{"home"}},
+				{Id: "3", Title: "3", Tags: []string{"work", "home"}},
+			}},
+			"tags": []Tag{
+				{Name: "work", Label: "Work", Color: "#ff0000", SortType: "project", SortOrder: 1},
+				{Name: "urgent", Label: "Urgent", Parent: "work", SortOrder: 2},
+				{Name: "home", Label: "home", SortOrder: 3},
+			},
+		})
+	client, _ := NewClient("testuser", "testpass", "test")
+	return client
+}
+
+func taskTags(c *Client) map[string][]string {
+	res := make(map[string][]string)
+	for _, t := range c.tasks {
+		res[t.Id] = t.Tags
+	}
+	return res
+}
+
+func TestListTags(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildTagClient()
+	assert.Equal(Tag{Name: "work", Label: "Work", Color: "#ff0000", SortType: "project", SortOrder: 1}, client.tags[0])
+
+	// normal case
+	NewSyncTestServer(BuildSyncResponse())
+	tags, err := client.ListTags()
+	assert.Nil(err)
+	assert.Equal([]Tag{{Name: "a"}, {Name: "b"}, {Name: "c"}}, tags)
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(404)
+	tags, err = client.ListTags()
+	assert.Nil(tags)
+	assert.NotNil(err)
+}
+
+func TestCreateTag(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildTagClient()
+
+	gock.New(baseUrlV2Test).
+		Post(tagBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]any{"add": []Tag{{Name: "errands", Label: "Errands", Parent: "home"}}}).
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{"errands": "etag"}})
+
+	// normal case
+	tag, err := client.CreateTag(&Tag{Label: "Errands", Parent: "home"})
+	assert.Nil(err)
+	assert.Equal(&Tag{Name: "errands", Label: "Errands", Parent: "home"}, tag)
+	assert.Equal([]string{"work", "urgent", "home", "errands"}, tagNames(client.tags))
+
+	// invalid tags
+	_, err = client.CreateTag(&Tag{})
+	assert.NotNil(err)
+	_, err = client.CreateTag(&Tag{Name: "Work"})
+	if assert.NotNil(err) {
+		assert.Contains(fmt.Sprint(err), "already exists")
+	}
+	_, err = client.CreateTag(&Tag{Name: "new", Parent: "random"})
+	if assert.NotNil(err) {
+		assert.Contains(fmt.Sprint(err), "parent tag random not found")
+	}
+	_, err = client.CreateTag(&Tag{Name: "new", Parent: "urgent"})
+	if assert.NotNil(err) {
+		assert.Contains(fmt.Sprint(err), "one level deep")
+	}
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Post(tagBatchUrlEndpoint).
+		Reply(500)
+	_, err = client.CreateTag(&Tag{Name: "new"})
+	assert.NotNil(err)
+}
+
+func TestSetTagParent(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildTagClient()
+
+	gock.New(baseUrlV2Test).
+		Post(tagBatchUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]any{"update": []Tag{{Name: "home", Label: "home", Parent: "work", SortOrder: 3}}}).
+		Reply(200).
+		JSON(map[string]any{})
+
+	// normal case
+	tag, err := client.SetTagParent("Home", "Work")
+	assert.Nil(err)
+	assert.Equal("work", tag.Parent)
+	assert.Equal("work", client.tags[2].Parent)
+
+	// back to the top level
+	gock.New(baseUrlV2Test).
+		Post(tagBatchUrlEndpoint).
+		MatchType("json").
+		JSON(map[string]any{"update": []Tag{{Name: "urgent", Label: "Urgent", SortOrder: 2}}}).
+		Reply(200).
+		JSON(map[string]any{})
+	tag, err = client.SetTagParent("urgent", "")
+	assert.Nil(err)
+	assert.Empty(tag.Parent)
+
+	// invalid nesting
+	_, err = client.SetTagParent("random", "work")
+	assert.NotNil(err)
+	_, err = client.SetTagParent("work", "work")
+	assert.NotNil(err)
+	_, err = client.SetTagParent("work", "urgent")
+	if assert.NotNil(err) {
+		assert.Contains(fmt.Sprint(err), "has children")
+	}
+
+	// the server refuses it
+	gock.New(baseUrlV2Test).
+		Post(tagBatchUrlEndpoint).
+		Reply(200).
+		JSON(map[string]any{"id2error": map[string]string{"urgent": "TAG_NOT_EXIST"}})
+	_, err = client.SetTagParent("urgent", "home")
+	assert.NotNil(err)
+	assert.Empty(client.tags[1].Parent)
+}
+
+func TestRenameTag(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildTagClient()
+
+	gock.New(baseUrlV2Test).
+		Put(tagRenameUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]string{"name": "work", "newName": "Office"}).
+		Reply(200)
+
+	// normal case, the tasks and the children follow
+	assert.Nil(client.RenameTag("Work", "Office"))
+	assert.Equal([]string{"office", "urgent", "home"}, tagNames(client.tags))
+	assert.Equal("Office", client.tags[0].Label)
+	assert.Equal("#ff0000", client.tags[0].Color)
+	assert.Equal("office", client.tags[1].Parent)
+	assert.Equal(map[string][]string{
+		"1": {"office", "urgent"},
+		"2": {"home"},
+		"3": {"office", "home"},
+	}, taskTags(client))
+
+	// empty names
+	assert.NotNil(client.RenameTag("", "new"))
+	assert.NotNil(client.RenameTag("home", ""))
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Put(tagRenameUrlEndpoint).
+		Reply(500)
+	assert.NotNil(client.RenameTag("home", "house"))
+	assert.Equal([]string{"office", "urgent", "home"}, tagNames(client.tags))
+}
+
+func TestMergeTags(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildTagClient()
+
+	gock.New(baseUrlV2Test).
+		Put(tagMergeUrlEndpoint).
+		MatchHeader("Cookie", "t=testtoken").
+		MatchType("json").
+		JSON(map[string]string{"name": "home", "newName": "work"}).
+		Reply(200)
+
+	// normal case, no task ends up with the tag twice
+	assert.Nil(client.MergeTags("home", "work"))
+	assert.Equal([]string{"work", "urgent"}, tagNames(client.tags))
+	assert.Equal(map[string][]string{
+		"1": {"work", "urgent"},
+		"2": {"work"},
+		"3": {"work"},
+	}, taskTags(client))
+
+	// the target keeps its own color and order when it comes after the merged tag
+	client = BuildTagClient()
+	gock.New(baseUrlV2Test).
+		Put(tagMergeUrlEndpoint).
+		JSON(map[string]string{"name": "work", "newName": "home"}).
+		Reply(200)
+	assert.Nil(client.MergeTags("work", "home"))
+	assert.Equal([]Tag{
+		{Name: "urgent", Label: "Urgent", Parent: "home", SortOrder: 2},
+		{Name: "home", Label: "home", SortOrder: 3},
+	}, client.tags)
+
+	// a child merged into a nested tag moves next to it, a tag merged into its child lifts it
+	client = BuildTagClient()
+	client.tags = append(client.tags, Tag{Name: "garden", Label: "garden", Parent: "home", SortOrder: 4})
+	gock.New(baseUrlV2Test).
+		Put(tagMergeUrlEndpoint).
+		JSON(map[string]string{"name": "work", "newName": "garden"}).
+		Reply(200)
+	assert.Nil(client.MergeTags("work", "garden"))
+	assert.Equal("home", client.tags[0].Parent)
+	gock.New(baseUrlV2Test).
+		Put(tagMergeUrlEndpoint).
+		JSON(map[string]string{"name": "home", "newName": "urgent"}).
+		Reply(200)
+	assert.Nil(client.MergeTags("home", "urgent"))
+	assert.Equal([]Tag{
+		{Name: "urgent", Label: "Urgent", SortOrder: 2},
+		{Name: "garden", Label: "garden", Parent: "urgent", SortOrder: 4},
+	}, client.tags)
+
+	// empty names
+	assert.NotNil(client.MergeTags("", "work"))
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Put(tagMergeUrlEndpoint).
+		Reply(500)
+	assert.NotNil(client.MergeTags("urgent", "work"))
+}
+
+func TestDeleteTag(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	client := BuildTagClient()
+
+	gock.New(baseUrlV2Test).
+		Delete(tagDeleteUrlEndpoint).
+		MatchParam("name", "work").
+		MatchHeader("Cookie", "t=testtoken").
+		Reply(200)
+
+	// normal case, the child moves to the top level
+	assert.Nil(client.DeleteTag("Work"))
+	assert.Equal([]string{"urgent", "home"}, tagNames(client.tags))
+	assert.Empty(client.tags[0].Parent)
+	assert.Equal(map[string][]string{
+		"1": {"urgent"},
+		"2": {"home"},
+		"3": {"home"},
+	}, taskTags(client))
+
+	// empty name
+	assert.NotNil(client.DeleteTag(""))
+
+	// server error
+	gock.New(baseUrlV2Test).
+		Delete(tagDeleteUrlEndpoint).
+		Reply(404)
+	assert.NotNil(client.DeleteTag("home"))
+}
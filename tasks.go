@@ -2,7 +2,12 @@ package ticktick
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,7 +16,7 @@ import (
 
 const (
 	taskCreateUrlEndpoint  = "/task"              // POST
-	taskDeleteUrlEndpoint  = "/batch/task"        // POST
+	taskDeleteUrlEndpoint  = "/batch/task"        // POST, also used by BatchTasks
 	taskUpdateUrlEndpoint  = "/task/%v"           // POST
 	MakeSubtaskUrlEndpoint = "/batch/taskParent"  // POST
 	MoveTaskUrlEndpoint    = "/batch/taskProject" // POST
@@ -22,45 +27,121 @@ type TaskItem struct {
 	ProjectId   string `json:"projectId"`
 	ProjectName string `json:"-"`
 	ParentId    string `json:"parentId"`
+	ColumnId    string `json:"columnId,omitempty"` // the section in the project, see ListSections
 
 	Title string `json:"title"`
 
-	IsAllDay  bool     `json:"isAllDay"`
-	Tags      []string `json:"tags"`
-	Content   string   `json:"content"`
-	Desc      string   `json:"desc"`
-	AllDay    bool     `json:"allDay"`
-	StartDate string   `json:"startDate"` // the dates are all in UTC
-	DueDate   string   `json:"dueDate"`   // and will not be influenced by TimeZone
-	TimeZone  string   `json:"timeZone"`
-	Reminders []string `json:"reminders"`
-	Repeat    string   `json:"repeat"`
-	Priority  int64    `json:"priority"`
-	SortOrder int64    `json:"sortOrder"`
-	Kind      string   `json:"kind"`
-	Status    int64    `json:"status"`
+	IsAllDay      bool         `json:"isAllDay"`
+	Tags          []string     `json:"tags"`
+	Content       string       `json:"content"`
+	Desc          string       `json:"desc"`
+	AllDay        bool         `json:"allDay"`
+	StartDate     TickTickTime `json:"startDate"` // the dates are all in UTC, see StartIn and DueIn
+	DueDate       TickTickTime `json:"dueDate"`   // for the all day tasks in TimeZone
+	TimeZone      string       `json:"timeZone"`
+	Reminders     []Reminder   `json:"reminders"`
+	Repeat        string       `json:"repeat"`     // see RepeatRule and SetRepeat
+	RepeatFrom    RepeatFrom   `json:"repeatFrom"` // RepeatFromDueDate or RepeatFromCompletedTime
+	Priority      int64        `json:"priority"`
+	SortOrder     int64        `json:"sortOrder"`
+	Kind          string       `json:"kind"`   // TEXT, NOTE or CHECKLIST
+	Status        int64        `json:"status"` // Open, Completed or Abandoned
+	CompletedTime TickTickTime `json:"completedTime"`
+
+	Items []ChecklistItem `json:"items"` // only for the CHECKLIST tasks
+
+	// the fields we do not model (etag, attachments...), kept as the server sent them
+	// so that UpdateTask does not drop them
+	unknownFields map[string]json.RawMessage
+}
+
+// TaskItem without its json methods
+type taskItemFields TaskItem
+
+// the json keys of the modeled fields
+var taskItemKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	typ := reflect.TypeOf(taskItemFields{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+func (t *TaskItem) UnmarshalJSON(data []byte) error {
+	fields := taskItemFields(*t)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for key := range raw {
+		if taskItemKeys[key] {
+			delete(raw, key)
+		}
+	}
+	fields.unknownFields = nil
+	if len(raw) > 0 {
+		fields.unknownFields = raw
+	}
+	*t = TaskItem(fields)
+	return nil
+}
+
+func (t TaskItem) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(taskItemFields(t))
+	if err != nil || len(t.unknownFields) == 0 {
+		return data, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range t.unknownFields {
+		if _, ok := merged[key]; !ok {
+			merged[key] = value
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// the raw json of the fields sent by the server that TaskItem does not model
+func (t TaskItem) UnknownFields() map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage, len(t.unknownFields))
+	for key, value := range t.unknownFields {
+		fields[key] = value
+	}
+	return fields
+}
+
+// a copy not sharing its slices and maps with t, for the tasks handed out of the snapshot
+func (t TaskItem) clone() TaskItem {
+	t.Tags = slices.Clone(t.Tags)
+	t.Reminders = slices.Clone(t.Reminders)
+	t.Items = slices.Clone(t.Items)
+	t.unknownFields = maps.Clone(t.unknownFields)
+	return t
 }
 
 func NewTask(c *Client, title string, content string, startDate time.Time, projectName string) (*TaskItem, error) {
 	projectId := ""
 	if projectName != "" {
-		pid, ok := c.projectName2Id[projectName]
+		pid, ok := c.projectId(projectName)
 		if !ok {
-			return nil, fmt.Errorf("projectName %v not found", projectName)
+			return nil, fmt.Errorf("%w: %v", ErrProjectNotFound, projectName)
 		} else {
 			projectId = pid
 		}
 	}
 
-	startDateString := ""
-	if !startDate.IsZero() {
-		startDateString = startDate.Format(TemplateTime)
-	}
-
 	t := TaskItem{
 		Title:       title,
 		Content:     content,
-		StartDate:   startDateString,
+		StartDate:   NewTickTickTime(startDate),
 		ProjectId:   projectId,
 		ProjectName: projectName,
 	}
@@ -69,27 +150,36 @@ func NewTask(c *Client, title string, content string, startDate time.Time, proje
 
 // CURD, Create
 func (c *Client) CreateTask(t *TaskItem) (*TaskItem, error) {
+	return c.CreateTaskCtx(context.Background(), t)
+}
+
+// CreateTaskCtx is CreateTask with a context
+func (c *Client) CreateTaskCtx(ctx context.Context, t *TaskItem) (*TaskItem, error) {
 	if t.Id != "" {
-		return nil, fmt.Errorf("the task has already been created with id=%v", t.Id)
+		return nil, fmt.Errorf("%w with id=%v", ErrTaskAlreadyCreated, t.Id)
 	}
 	var resp TaskItem
-	if err := requests.
-		URL(c.baseUrlV2+taskCreateUrlEndpoint).
-		Cookie("t", c.loginToken).
-		BodyJSON(t).
-		ToJSON(&resp).
-		Fetch(context.Background()); err != nil {
+	if err := c.fetch(ctx, func() *requests.Builder {
+		return c.request(taskCreateUrlEndpoint).
+			BodyJSON(t).
+			ToJSON(&resp)
+	}); err != nil {
 		return nil, err
 	}
 
-	resp.ProjectName = c.id2ProjectName[resp.ProjectId]
+	resp.ProjectName = c.projectName(resp.ProjectId)
 	return &resp, nil
 }
 
 // CURD, Delete
 func (c *Client) DeleteTask(t *TaskItem) (*TaskItem, error) {
+	return c.DeleteTaskCtx(context.Background(), t)
+}
+
+// DeleteTaskCtx is DeleteTask with a context
+func (c *Client) DeleteTaskCtx(ctx context.Context, t *TaskItem) (*TaskItem, error) {
 	if t.Id == "" {
-		return nil, fmt.Errorf("the task has not been created, thus not deleted")
+		return nil, fmt.Errorf("the %w, thus not deleted", ErrTaskNotCreated)
 	}
 
 	type deleteElement struct {
@@ -107,11 +197,10 @@ func (c *Client) DeleteTask(t *TaskItem) (*TaskItem, error) {
 		},
 	}
 
-	if err := requests.
-		URL(c.baseUrlV2+taskDeleteUrlEndpoint).
-		Cookie("t", c.loginToken).
-		BodyJSON(&body).
-		Fetch(context.Background()); err != nil {
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(taskDeleteUrlEndpoint).
+			BodyJSON(&body)
+	}); err != nil {
 		return nil, err
 	}
 
@@ -127,8 +216,23 @@ func (c *Client) DeleteTask(t *TaskItem) (*TaskItem, error) {
 // CURD, Read, partial match. if parameter is "", it will have no effect.
 // If priority is -1, it's ignored. If time is zero val, it's ignored.
 // Priority values are: 0,1,3,5 (low -> high)
+//
+// Deprecated: use QueryTasks, which filters on more fields and combines the conditions
 func (c *Client) SearchTask(title string, project string, tag string, id string, StartDateNotbefore time.Time, StartDateNotafter time.Time, priority int64) ([]TaskItem, error) {
-	c.Sync()
+	return c.SearchTaskCtx(context.Background(), title, project, tag, id, StartDateNotbefore, StartDateNotafter, priority)
+}
+
+// SearchTaskCtx is SearchTask with a context
+//
+// Deprecated: use QueryTasksCtx
+func (c *Client) SearchTaskCtx(ctx context.Context, title string, project string, tag string, id string, StartDateNotbefore time.Time, StartDateNotafter time.Time, priority int64) ([]TaskItem, error) {
+	// search the last snapshot if the sync fails, unless the caller gave up or has to sign in
+	if err := c.SyncCtx(ctx); err != nil && (ctx.Err() != nil || errors.Is(err, ErrUnauthorized)) {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
 	var res []TaskItem
 	for _, task := range c.tasks {
@@ -144,13 +248,13 @@ func (c *Client) SearchTask(title string, project string, tag string, id string,
 		if id != "" && task.Id != id {
 			continue
 		}
-		if taskTime, _ := time.Parse(TemplateTime, task.StartDate); !StartDateNotbefore.IsZero() && !StartDateNotafter.IsZero() && (taskTime.Before(StartDateNotbefore) || taskTime.After(StartDateNotafter)) {
+		if !StartDateNotbefore.IsZero() && !StartDateNotafter.IsZero() && (task.StartDate.IsZero() || task.StartDate.Before(StartDateNotbefore) || task.StartDate.After(StartDateNotafter)) {
 			continue
 		}
 		if priority != -1 && priority != task.Priority {
 			continue
 		}
-		res = append(res, task)
+		res = append(res, task.clone())
 	}
 
 	return res, nil
@@ -158,42 +262,57 @@ func (c *Client) SearchTask(title string, project string, tag string, id string,
 
 // CURD, Update
 func (c *Client) UpdateTask(t *TaskItem) (*TaskItem, error) {
+	return c.UpdateTaskCtx(context.Background(), t)
+}
+
+// UpdateTaskCtx is UpdateTask with a context
+func (c *Client) UpdateTaskCtx(ctx context.Context, t *TaskItem) (*TaskItem, error) {
 	if t.Id == "" {
-		return nil, fmt.Errorf("task Id is empty")
+		return nil, fmt.Errorf("%w: task Id is empty", ErrTaskNotCreated)
 	}
 	var resp TaskItem
-	if err := requests.
-		URL(fmt.Sprintf(c.baseUrlV2+taskUpdateUrlEndpoint, t.Id)).
-		Cookie("t", c.loginToken).
-		BodyJSON(t).
-		ToJSON(&resp).
-		Fetch(context.Background()); err != nil {
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(fmt.Sprintf(taskUpdateUrlEndpoint, t.Id)).
+			BodyJSON(t).
+			ToJSON(&resp)
+	}); err != nil {
 		return nil, err
 	}
 
-	resp.ProjectName = c.id2ProjectName[resp.ProjectId]
+	resp.ProjectName = c.projectName(resp.ProjectId)
 	return &resp, nil
 }
 
 // Complete task, as complete has no field
 func (c *Client) CompleteTask(t *TaskItem) (*TaskItem, error) {
+	return c.CompleteTaskCtx(context.Background(), t)
+}
+
+// CompleteTaskCtx is CompleteTask with a context
+func (c *Client) CompleteTaskCtx(ctx context.Context, t *TaskItem) (*TaskItem, error) {
 	newt := *t
-	newt.Status = 2
-	return c.UpdateTask(&newt)
+	newt.Status = Completed
+	return c.UpdateTaskCtx(ctx, &newt)
 }
 
-// Make subtask, p is the parent, t is the child, return the parent and child tasks
+// Make subtask, p is the parent, t is the child, return the parent and child tasks. The
+// error of the server about the child, such as TASK_NOT_FOUND, is an *ItemError.
 func (c *Client) MakeSubtask(p, t *TaskItem) (*TaskItem, *TaskItem, error) {
+	return c.MakeSubtaskCtx(context.Background(), p, t)
+}
+
+// MakeSubtaskCtx is MakeSubtask with a context
+func (c *Client) MakeSubtaskCtx(ctx context.Context, p, t *TaskItem) (*TaskItem, *TaskItem, error) {
 	if p.Id == "" {
-		return nil, nil, fmt.Errorf("the parent has not been created")
+		return nil, nil, fmt.Errorf("the parent has not been created: %w", ErrTaskNotCreated)
 	}
 	if t.Id == "" {
-		return nil, nil, fmt.Errorf("the child has not been created")
+		return nil, nil, fmt.Errorf("the child has not been created: %w", ErrTaskNotCreated)
 	}
 
 	// if p and t not in the same project, move t to p's project
 	if p.ProjectId != t.ProjectId {
-		newt, err := c.MoveTask(t, p.ProjectName)
+		newt, err := c.MoveTaskCtx(ctx, t, p.ProjectName)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -212,41 +331,52 @@ func (c *Client) MakeSubtask(p, t *TaskItem) (*TaskItem, *TaskItem, error) {
 		TaskId:    t.Id,
 	})
 
-	if err := requests.
-		URL(c.baseUrlV2+MakeSubtaskUrlEndpoint).
-		Cookie("t", c.loginToken).
-		BodyJSON(body).
-		Fetch(context.Background()); err != nil {
+	var resp batchResponse
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(MakeSubtaskUrlEndpoint).
+			BodyJSON(body).
+			ToJSON(&resp)
+	}); err != nil {
+		return nil, nil, err
+	}
+	if err := resp.itemError(t.Id); err != nil {
 		return nil, nil, err
 	}
 
 	// as the response is not the task itself, we sync and search
-	c.Sync()
-	newPList, err := c.SearchTask("", "", "", p.Id, time.Time{}, time.Time{}, -1)
+	c.SyncCtx(ctx)
+	newPList, err := c.SearchTaskCtx(ctx, "", "", "", p.Id, time.Time{}, time.Time{}, -1)
 	if err != nil {
 		return nil, nil, err
 	}
 	if len(newPList) == 0 {
-		return nil, nil, fmt.Errorf("server error in response to get the parent task")
+		return nil, nil, fmt.Errorf("%w: server error in response to get the parent task", ErrTaskNotFound)
 	}
-	newCList, err := c.SearchTask("", "", "", t.Id, time.Time{}, time.Time{}, -1)
+	newCList, err := c.SearchTaskCtx(ctx, "", "", "", t.Id, time.Time{}, time.Time{}, -1)
 	if err != nil {
 		return nil, nil, err
 	}
 	if len(newCList) == 0 {
-		return nil, nil, fmt.Errorf("server error in response to get the child task")
+		return nil, nil, fmt.Errorf("%w: server error in response to get the child task", ErrTaskNotFound)
 	}
 	return &newPList[0], &newCList[0], nil
 }
 
-// Move task to another project, as directly updating projectId has no effect
+// Move task to another project, as directly updating projectId has no effect. The returned
+// task has the id and the name of the new project, the error of the server about the task is
+// an *ItemError.
 func (c *Client) MoveTask(t *TaskItem, to string) (*TaskItem, error) {
+	return c.MoveTaskCtx(context.Background(), t, to)
+}
+
+// MoveTaskCtx is MoveTask with a context
+func (c *Client) MoveTaskCtx(ctx context.Context, t *TaskItem, to string) (*TaskItem, error) {
 	if t.ProjectName == to {
 		return t, nil
 	}
-	toId, ok := c.projectName2Id[to]
+	toId, ok := c.projectId(to)
 	if !ok {
-		return nil, fmt.Errorf("the project name %v not exist", to)
+		return nil, fmt.Errorf("%w: %v", ErrProjectNotFound, to)
 	}
 
 	type bodyElement struct {
@@ -261,15 +391,20 @@ func (c *Client) MoveTask(t *TaskItem, to string) (*TaskItem, error) {
 		ToProjectId:   toId,
 	})
 
-	if err := requests.
-		URL(c.baseUrlV2+MoveTaskUrlEndpoint).
-		Cookie("t", c.loginToken).
-		BodyJSON(body).
-		Fetch(context.Background()); err != nil {
+	var resp batchResponse
+	if err := c.fetchIdempotent(ctx, func() *requests.Builder {
+		return c.request(MoveTaskUrlEndpoint).
+			BodyJSON(body).
+			ToJSON(&resp)
+	}); err != nil {
+		return nil, err
+	}
+	if err := resp.itemError(t.Id); err != nil {
 		return nil, err
 	}
 
 	newt := *t
 	newt.ProjectId = toId
+	newt.ProjectName = c.projectName(toId)
 	return &newt, nil
 }
@@ -1,12 +1,17 @@
 package ticktick
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
+	"github.com/ziyixi/go-ticktick/cassette"
 )
 
 // ********* test utils ********* //
@@ -34,7 +39,7 @@ func TestNewTask(t *testing.T) {
 		TaskItem{
 			Title:       "test",
 			Content:     "testcontent",
-			StartDate:   exampleTime.Format(TemplateTime),
+			StartDate:   NewTickTickTime(exampleTime),
 			ProjectId:   "pid1",
 			ProjectName: "pname1",
 		},
@@ -48,7 +53,7 @@ func TestNewTask(t *testing.T) {
 		TaskItem{
 			Title:       "test",
 			Content:     "testcontent",
-			StartDate:   "",
+			StartDate:   TickTickTime{},
 			ProjectId:   "pid1",
 			ProjectName: "pname1",
 		},
@@ -60,6 +65,7 @@ func TestNewTask(t *testing.T) {
 	assert.Nil(task)
 	if assert.NotNil(err) {
 		assert.Contains(fmt.Sprint(err), "not found")
+		assert.True(errors.Is(err, ErrProjectNotFound))
 	}
 }
 
@@ -90,6 +96,7 @@ func TestCreateTask(t *testing.T) {
 	assert.Nil(newtask)
 	if assert.NotNil(err) {
 		assert.Contains(fmt.Sprint(err), "the task has already been created with id")
+		assert.True(errors.Is(err, ErrTaskAlreadyCreated))
 	}
 
 	// if no response due to the server error
@@ -144,6 +151,7 @@ func TestDeleteTask(t *testing.T) {
 	assert.Nil(ntask)
 	if assert.NotNil(err) {
 		assert.Contains(fmt.Sprint(err), "the task has not been created, thus not deleted")
+		assert.True(errors.Is(err, ErrTaskNotCreated))
 	}
 
 	// server error
@@ -224,6 +232,7 @@ func TestUpdateTask(t *testing.T) {
 	assert.Nil(ntask)
 	if assert.NotNil(err) {
 		assert.Contains(fmt.Sprint(err), "task Id is empty")
+		assert.True(errors.Is(err, ErrTaskNotCreated))
 	}
 
 	// server error
@@ -234,6 +243,10 @@ func TestUpdateTask(t *testing.T) {
 	ntask, err = client.UpdateTask(&taskUpdated)
 	assert.Nil(ntask)
 	assert.NotNil(err)
+	var apiErr *APIError
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(404, apiErr.StatusCode)
+	}
 }
 
 func TestCompleteTask(t *testing.T) {
@@ -278,7 +291,8 @@ func TestMakeSubtask(t *testing.T) {
 		MatchHeader("Cookie", "t=testtoken").
 		MatchType("json").
 		JSON(body).
-		Reply(200)
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{}, "id2error": map[string]string{}})
 
 	// normal case, when both are in the same dir
 	taskp, _ := client.SearchTask("1", "", "", "", time.Time{}, time.Time{}, -1)
@@ -293,6 +307,7 @@ func TestMakeSubtask(t *testing.T) {
 	_, _, err = client.MakeSubtask(&taskp[0], &taskc[0])
 	if assert.NotNil(err) {
 		assert.Contains(fmt.Sprint(err), "the parent has not been created")
+		assert.True(errors.Is(err, ErrTaskNotCreated))
 	}
 	taskp[0].Id = "1"
 
@@ -301,6 +316,7 @@ func TestMakeSubtask(t *testing.T) {
 	_, _, err = client.MakeSubtask(&taskp[0], &taskc[0])
 	if assert.NotNil(err) {
 		assert.Contains(fmt.Sprint(err), "the child has not been created")
+		assert.True(errors.Is(err, ErrTaskNotCreated))
 	}
 	taskc[0].Id = "1"
 
@@ -313,11 +329,12 @@ func TestMakeSubtask(t *testing.T) {
 		Post(MoveTaskUrlEndpoint).
 		MatchHeader("Cookie", "t=testtoken").
 		Reply(200).
-		JSON(taskcUpdated)
+		JSON(map[string]any{"id2etag": map[string]string{}, "id2error": map[string]string{}})
 	gock.New(baseUrlV2Test).
 		Post(MakeSubtaskUrlEndpoint).
 		MatchHeader("Cookie", "t=testtoken").
-		Reply(200)
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{}, "id2error": map[string]string{}})
 	newp, newt, err = client.MakeSubtask(&taskp[0], &taskc[0])
 	assert.NotNil(newp)
 	assert.NotNil(newt)
@@ -335,6 +352,45 @@ func TestMakeSubtask(t *testing.T) {
 	assert.NotNil(err)
 }
 
+// the client of a cassette test, replaying the cassette at path
+func cassetteClient(t *testing.T, path string) (client *Client, unused func() []cassette.Interaction) {
+	rep, err := cassette.NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err = NewClientWithOptions(
+		WithCredentials("testuser", "testpass"),
+		WithServer("ticktick"),
+		WithTransport(rep),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, rep.Unused
+}
+
+// testdata/make_subtask_synthetic.json is a synthetic fixture written by hand in the cassette
+// format, it was not recorded from the real server and its ids and checkpoints are made up
+func TestMakeSubtaskCassette(t *testing.T) {
+	assert := assert.New(t)
+	client, unused := cassetteClient(t, "testdata/make_subtask_synthetic.json")
+
+	// normal case
+	parent, _ := client.QueryTasks(Query().TitleContains("weekly report"))
+	child, _ := client.QueryTasks(Query().TitleContains("collect the numbers"))
+	if !assert.Len(parent, 1) || !assert.Len(child, 1) {
+		return
+	}
+	newp, newc, err := client.MakeSubtask(&parent[0], &child[0])
+	assert.Nil(err)
+	if assert.NotNil(newp) && assert.NotNil(newc) {
+		assert.Equal(newp.Id, newc.ParentId)
+		assert.Equal("work", newc.ProjectName)
+	}
+	assert.Empty(unused())
+}
+
 func TestMoveTask(t *testing.T) {
 	defer gock.Off()
 	assert := assert.New(t)
@@ -360,12 +416,17 @@ func TestMoveTask(t *testing.T) {
 		MatchHeader("Cookie", "t=testtoken").
 		MatchType("json").
 		JSON(body).
-		Reply(200)
+		Reply(200).
+		JSON(map[string]any{"id2etag": map[string]string{}, "id2error": map[string]string{}})
 
 	// normal case
 	newt, err := client.MoveTask(task, "pname2")
-	assert.NotNil(newt)
 	assert.Nil(err)
+	if assert.NotNil(newt) {
+		assert.Equal("pid2", newt.ProjectId)
+		assert.Equal("pname2", newt.ProjectName)
+		assert.Equal("pname1", task.ProjectName)
+	}
 
 	// if move to the same project
 	newt, err = client.MoveTask(task, "pname1")
@@ -376,7 +437,7 @@ func TestMoveTask(t *testing.T) {
 	// if the new project not exists
 	newt, err = client.MoveTask(task, "pnameRandom")
 	assert.Nil(newt)
-	assert.NotNil(err)
+	assert.True(errors.Is(err, ErrProjectNotFound))
 
 	// if server error
 	gock.New(baseUrlV2Test).
@@ -386,3 +447,202 @@ func TestMoveTask(t *testing.T) {
 	assert.Nil(newt)
 	assert.NotNil(err)
 }
+
+func TestTaskCtx(t *testing.T) {
+	assert := assert.New(t)
+	server, _ := NewBlockingTestServer()
+	defer server.Close()
+	client := BuildBlockingClient(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	task := &TaskItem{Title: "test", ProjectId: "pid1", ProjectName: "pname1"}
+	created := &TaskItem{Id: "1", Title: "test", ProjectId: "pid1", ProjectName: "pname1"}
+	parent := &TaskItem{Id: "2", Title: "test", ProjectId: "pid1", ProjectName: "pname1"}
+
+	_, err := client.CreateTaskCtx(ctx, task)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	_, err = client.UpdateTaskCtx(ctx, created)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	_, err = client.CompleteTaskCtx(ctx, created)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	_, err = client.DeleteTaskCtx(ctx, created)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	_, err = client.MoveTaskCtx(ctx, created, "pname2")
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	_, _, err = client.MakeSubtaskCtx(ctx, parent, created)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	tasks, err := client.SearchTaskCtx(ctx, "", "", "", "", time.Time{}, time.Time{}, -1)
+	assert.Nil(tasks)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestTaskReauth(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+
+	created := &TaskItem{Id: "1", Title: "test", ProjectId: "pid1", ProjectName: "pname1"}
+	parent := &TaskItem{Id: "2", Title: "test", ProjectId: "pid1", ProjectName: "pname1"}
+	cases := []struct {
+		name     string
+		method   string
+		endpoint string
+		call     func(c *Client) error
+	}{
+		{"create", "POST", taskCreateUrlEndpoint, func(c *Client) error {
+			_, err := c.CreateTask(&TaskItem{Title: "test"})
+			return err
+		}},
+		{"update", "POST", fmt.Sprintf(taskUpdateUrlEndpoint, "1"), func(c *Client) error {
+			_, err := c.UpdateTask(created)
+			return err
+		}},
+		{"complete", "POST", fmt.Sprintf(taskUpdateUrlEndpoint, "1"), func(c *Client) error {
+			_, err := c.CompleteTask(created)
+			return err
+		}},
+		{"delete", "POST", taskDeleteUrlEndpoint, func(c *Client) error {
+			_, err := c.DeleteTask(created)
+			return err
+		}},
+		{"move", "POST", MoveTaskUrlEndpoint, func(c *Client) error {
+			_, err := c.MoveTask(created, "pname2")
+			return err
+		}},
+		{"subtask", "POST", MakeSubtaskUrlEndpoint, func(c *Client) error {
+			_, _, err := c.MakeSubtask(parent, created)
+			return err
+		}},
+		{"search", "GET", fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0), func(c *Client) error {
+			_, err := c.SearchTask("", "", "", "1", time.Time{}, time.Time{}, -1)
+			return err
+		}},
+	}
+
+	mockEndpoint := func(method, endpoint string) *gock.Request {
+		if method == "GET" {
+			return gock.New(baseUrlV2Test).Get(endpoint)
+		}
+		return gock.New(baseUrlV2Test).Post(endpoint)
+	}
+
+	for _, tc := range cases {
+		client := BuildSampleClient()
+		client.loginToken = "expiredtoken"
+
+		// rejected once, then accepted with the new token
+		mockEndpoint(tc.method, tc.endpoint).
+			MatchHeader("Cookie", "t=expiredtoken").
+			Reply(401)
+		NewSignInTestServer()
+		mockEndpoint(tc.method, tc.endpoint).
+			MatchHeader("Cookie", "t=testtoken").
+			Reply(200).
+			JSON(created)
+		if tc.name == "subtask" {
+			NewSyncTestServer(BuildSyncResponse())
+			NewSyncTestServer(BuildSyncResponse())
+		}
+		assert.Nil(tc.call(client), tc.name)
+		assert.Equal("testtoken", client.loginToken, tc.name)
+
+		// rejected again after signing in
+		client.loginToken = "expiredtoken"
+		mockEndpoint(tc.method, tc.endpoint).
+			Reply(401)
+		NewSignInTestServer()
+		mockEndpoint(tc.method, tc.endpoint).
+			Reply(403)
+		err := tc.call(client)
+		assert.True(errors.Is(err, ErrUnauthorized), tc.name)
+		gock.Off()
+	}
+}
+
+func TestTaskUnknownFields(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+
+	// a task with the fields we do not model
+	serverTask := `{
+		"id": "1", "projectId": "pid1", "title": "1", "priority": 5,
+		"etag": "abc123", "creator": 7, "assignee": 42, "progress": 50,
+		"attachments": [{"id": "a1", "fileName": "a.png"}],
+		"focusSummaries": [{"pomoCount": 2}], "pinnedTime": "2023-01-01T00:00:00.000+0000"
+	}`
+	var task TaskItem
+	assert.Nil(json.Unmarshal([]byte(serverTask), &task))
+	assert.Equal("1", task.Title)
+	assert.Equal(int64(5), task.Priority)
+	unknown := task.UnknownFields()
+	assert.Len(unknown, 7)
+	assert.JSONEq(`[{"id": "a1", "fileName": "a.png"}]`, string(unknown["attachments"]))
+
+	// they survive a marshal, while the modeled fields win
+	task.Title = "updated"
+	data, err := json.Marshal(task)
+	assert.Nil(err)
+	var sent map[string]any
+	json.Unmarshal(data, &sent)
+	assert.Equal("updated", sent["title"])
+	assert.Equal("abc123", sent["etag"])
+	assert.Equal(float64(7), sent["creator"])
+	assert.Equal(float64(42), sent["assignee"])
+	assert.Equal(float64(50), sent["progress"])
+	assert.Equal("2023-01-01T00:00:00.000+0000", sent["pinnedTime"])
+	assert.Len(sent["attachments"], 1)
+	assert.Len(sent["focusSummaries"], 1)
+
+	// the tasks without unknown fields stay comparable
+	var plain TaskItem
+	assert.Nil(json.Unmarshal([]byte(`{"id": "2", "title": "2"}`), &plain))
+	assert.Equal(TaskItem{Id: "2", Title: "2"}, plain)
+	assert.Empty(plain.UnknownFields())
+
+	// the project name is kept when unmarshalling into an existing task
+	named := TaskItem{ProjectName: "pname1"}
+	assert.Nil(json.Unmarshal([]byte(`{"id": "3"}`), &named))
+	assert.Equal("pname1", named.ProjectName)
+	assert.NotNil(json.Unmarshal([]byte(`{"id": 3}`), &named))
+
+	// regression: a synced task keeps them through UpdateTask
+	NewSignInTestServer()
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(200).
+		BodyString(`{"inboxId": "testinboxid", "projectProfiles": [{"id": "pid1", "name": "pname1"}],
+			"syncTaskBean": {"update": [` + serverTask + `]}, "tags": []}`)
+	client, err := NewClient("testuser", "testpass", "test")
+	if !assert.Nil(err) {
+		return
+	}
+	tasks, _ := client.SearchTask("", "", "", "1", time.Time{}, time.Time{}, -1)
+	if !assert.Len(tasks, 1) {
+		return
+	}
+	tasks[0].Priority = 3
+	expected := map[string]any{}
+	json.Unmarshal([]byte(serverTask), &expected)
+	expected["priority"] = 3
+	gock.New(baseUrlV2Test).
+		Post(fmt.Sprintf(taskUpdateUrlEndpoint, "1")).
+		MatchType("json").
+		AddMatcher(func(r *http.Request, _ *gock.Request) (bool, error) {
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				return false, err
+			}
+			for key, value := range expected {
+				if fmt.Sprint(body[key]) != fmt.Sprint(value) {
+					return false, nil
+				}
+			}
+			return true, nil
+		}).
+		Reply(200).
+		BodyString(serverTask)
+	updated, err := client.UpdateTask(&tasks[0])
+	assert.Nil(err)
+	assert.JSONEq(`"abc123"`, string(updated.UnknownFields()["etag"]))
+	assert.True(gock.IsDone())
+}
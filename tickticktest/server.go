@@ -0,0 +1,471 @@
+// Package tickticktest provides a fake TickTick api server for the tests of code using ticktick.
+//
+// The server keeps the projects and the tasks in memory and implements the endpoints the client
+// needs for the task flows: signon, /batch/check, /projects, /task, /batch/task, /batch/taskParent
+// and /batch/taskProject.
+//
+//	srv := tickticktest.NewServer("user", "pass")
+//	defer srv.Close()
+//	client, err := ticktick.NewClientWithOptions(srv.Options()...)
+package tickticktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	ticktick "github.com/ziyixi/go-ticktick"
+)
+
+// Server is a fake TickTick api server, its base url is URL + "/api/v2"
+type Server struct {
+	*httptest.Server
+
+	userName string
+	passWord string
+
+	// mu guards the state below, every change bumps version, the checkpoint of the syncs
+	mu       sync.Mutex
+	token    string
+	userId   string
+	inboxId  string
+	version  int64
+	lastId   int64
+	projects []ticktick.Project
+	tasks    []*storedTask // in the order of creation
+	deleted  []deletedTask
+}
+
+type storedTask struct {
+	task    ticktick.TaskItem
+	version int64
+}
+
+type deletedTask struct {
+	ProjectId string `json:"projectId"`
+	TaskId    string `json:"taskId"`
+	version   int64
+}
+
+// Start a server accepting the credentials, close it when done
+func NewServer(userName, passWord string) *Server {
+	s := &Server{
+		userName: userName,
+		passWord: passWord,
+		userId:   "100000001",
+		inboxId:  "inbox100000001",
+	}
+	s.token = s.newId()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v2/user/signon", s.signOn)
+	mux.HandleFunc("GET /api/v2/batch/check/{checkPoint}", s.auth(s.batchCheck))
+	mux.HandleFunc("GET /api/v2/projects", s.auth(s.listProjects))
+	mux.HandleFunc("POST /api/v2/task", s.auth(s.createTask))
+	mux.HandleFunc("POST /api/v2/task/{id}", s.auth(s.updateTask))
+	mux.HandleFunc("POST /api/v2/batch/task", s.auth(s.batchTask))
+	mux.HandleFunc("POST /api/v2/batch/taskParent", s.auth(s.batchTaskParent))
+	mux.HandleFunc("POST /api/v2/batch/taskProject", s.auth(s.batchTaskProject))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", r.Method+" "+r.URL.Path+" is not implemented")
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// the api base url of the server
+func (s *Server) BaseURL() string {
+	return s.URL + "/api/v2"
+}
+
+// the options of a client signing in to the server
+func (s *Server) Options() []ticktick.Option {
+	return []ticktick.Option{
+		ticktick.WithCredentials(s.userName, s.passWord),
+		ticktick.WithBaseURL(s.BaseURL()),
+		ticktick.WithHTTPClient(s.Client()),
+	}
+}
+
+// the id of the inbox project
+func (s *Server) InboxId() string {
+	return s.inboxId
+}
+
+// Add a project, return it with its id
+func (s *Server) AddProject(name string) ticktick.Project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := ticktick.Project{Id: s.newId(), Name: name, Kind: "TASK", ViewMode: "list"}
+	s.projects = append(s.projects, p)
+	s.version++
+	return p
+}
+
+// Add a task as if it was created in the app, return it with its id
+func (s *Server) AddTask(t ticktick.TaskItem) ticktick.TaskItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.storeNewTask(t)
+}
+
+// the task with the id, as the server keeps it
+func (s *Server) Task(id string) (ticktick.TaskItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st := s.findTask(id); st != nil {
+		return st.task, true
+	}
+	return ticktick.TaskItem{}, false
+}
+
+// all the tasks, including the closed ones, in the order of creation
+func (s *Server) Tasks() []ticktick.TaskItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]ticktick.TaskItem, 0, len(s.tasks))
+	for _, st := range s.tasks {
+		tasks = append(tasks, st.task)
+	}
+	return tasks
+}
+
+// Reject the current login token, as when it expires. The clients have to sign in again.
+func (s *Server) RevokeToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = s.newId()
+}
+
+// an id in the format of the server ids, s.mu must be held
+func (s *Server) newId() string {
+	s.lastId++
+	return fmt.Sprintf("%024x", s.lastId)
+}
+
+func (s *Server) etag(version int64) string {
+	return fmt.Sprintf("%08x", version)
+}
+
+// s.mu must be held
+func (s *Server) findTask(id string) *storedTask {
+	for _, st := range s.tasks {
+		if st.task.Id == id {
+			return st
+		}
+	}
+	return nil
+}
+
+// s.mu must be held
+func (s *Server) hasProject(id string) bool {
+	if id == s.inboxId {
+		return true
+	}
+	for _, p := range s.projects {
+		if p.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// store a task without id or with a new id chosen by the client, s.mu must be held
+func (s *Server) storeNewTask(t ticktick.TaskItem) ticktick.TaskItem {
+	if t.Id == "" {
+		t.Id = s.newId()
+	}
+	if t.ProjectId == "" {
+		t.ProjectId = s.inboxId
+	}
+	if t.Kind == "" {
+		t.Kind = "TEXT"
+	}
+	t.ProjectName = ""
+	s.version++
+	s.tasks = append(s.tasks, &storedTask{task: t, version: s.version})
+	return t
+}
+
+// apply an update of the client, the project and the parent are only changed by their
+// batch endpoints, like the real server does. s.mu must be held
+func (s *Server) storeUpdate(st *storedTask, t ticktick.TaskItem) {
+	t.Id = st.task.Id
+	t.ProjectId = st.task.ProjectId
+	t.ParentId = st.task.ParentId
+	t.ProjectName = ""
+	switch {
+	case t.Status != ticktick.Open && t.CompletedTime.IsZero():
+		t.CompletedTime = ticktick.NewTickTickTime(time.Now())
+	case t.Status == ticktick.Open:
+		t.CompletedTime = ticktick.TickTickTime{}
+	}
+	s.version++
+	st.task, st.version = t, s.version
+}
+
+// s.mu must be held
+func (s *Server) deleteTask(projectId, id string) bool {
+	for i, st := range s.tasks {
+		if st.task.Id == id && st.task.ProjectId == projectId {
+			s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+			s.version++
+			s.deleted = append(s.deleted, deletedTask{ProjectId: projectId, TaskId: id, version: s.version})
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{"errorCode": code, "errorMessage": message})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return false
+	}
+	return true
+}
+
+// reject the requests without the current login token
+func (s *Server) auth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("t")
+		s.mu.Lock()
+		valid := err == nil && cookie.Value == s.token
+		s.mu.Unlock()
+		if !valid {
+			writeError(w, http.StatusUnauthorized, "user_not_sign_on", "the login token is missing or expired")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) signOn(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		UserName string `json:"username"`
+		PassWord string `json:"password"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.UserName != s.userName || body.PassWord != s.passWord {
+		writeError(w, http.StatusUnauthorized, "username_password_not_match", "the username or password is incorrect")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token":    s.token,
+		"userId":   s.userId,
+		"username": s.userName,
+		"inboxId":  s.inboxId,
+	})
+}
+
+// everything for the checkpoint 0, else the changes since the checkpoint.
+// Like the real server, the tasks closed since the checkpoint come as updates
+// with their new status, the full sync has only the open tasks.
+func (s *Server) batchCheck(w http.ResponseWriter, r *http.Request) {
+	checkPoint, err := strconv.ParseInt(r.PathValue("checkPoint"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_checkpoint", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update := []ticktick.TaskItem{}
+	deleted := []deletedTask{}
+	for _, st := range s.tasks {
+		if st.version <= checkPoint {
+			continue
+		}
+		if st.task.Status == ticktick.Open || checkPoint > 0 {
+			update = append(update, st.task)
+		}
+	}
+	if checkPoint > 0 {
+		for _, d := range s.deleted {
+			if d.version > checkPoint {
+				deleted = append(deleted, d)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"checkPoint":      s.version,
+		"inboxId":         s.inboxId,
+		"projectProfiles": append([]ticktick.Project{}, s.projects...),
+		"projectGroups":   []ticktick.ProjectGroup{},
+		"tags":            []ticktick.Tag{},
+		"filters":         []ticktick.Filter{},
+		"syncTaskBean": map[string]any{
+			"update": update,
+			"delete": deleted,
+			"empty":  len(update) == 0 && len(deleted) == 0,
+		},
+	})
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, append([]ticktick.Project{}, s.projects...))
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	var t ticktick.TaskItem
+	if !readJSON(w, r, &t) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.ProjectId != "" && !s.hasProject(t.ProjectId) {
+		writeError(w, http.StatusBadRequest, "project_not_found", "project "+t.ProjectId+" not found")
+		return
+	}
+	if t.Id != "" && s.findTask(t.Id) != nil {
+		writeError(w, http.StatusConflict, "task_exists", "task "+t.Id+" already exists")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.storeNewTask(t))
+}
+
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
+	var t ticktick.TaskItem
+	if !readJSON(w, r, &t) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.findTask(r.PathValue("id"))
+	if st == nil {
+		writeError(w, http.StatusNotFound, "task_not_found", "task "+r.PathValue("id")+" not found")
+		return
+	}
+	s.storeUpdate(st, t)
+	writeJSON(w, http.StatusOK, st.task)
+}
+
+type batchResponse struct {
+	Id2Etag  map[string]string `json:"id2etag"`
+	Id2Error map[string]string `json:"id2error"`
+}
+
+func newBatchResponse() batchResponse {
+	return batchResponse{Id2Etag: make(map[string]string), Id2Error: make(map[string]string)}
+}
+
+func (s *Server) batchTask(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Add    []ticktick.TaskItem `json:"add"`
+		Update []ticktick.TaskItem `json:"update"`
+		Delete []deletedTask       `json:"delete"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := newBatchResponse()
+	for _, t := range body.Add {
+		switch {
+		case t.Id != "" && s.findTask(t.Id) != nil:
+			resp.Id2Error[t.Id] = "TASK_EXISTS"
+		case t.ProjectId != "" && !s.hasProject(t.ProjectId):
+			resp.Id2Error[t.Id] = "PROJECT_NOT_FOUND"
+		default:
+			t = s.storeNewTask(t)
+			resp.Id2Etag[t.Id] = s.etag(s.version)
+		}
+	}
+	for _, t := range body.Update {
+		if st := s.findTask(t.Id); st != nil {
+			s.storeUpdate(st, t)
+			resp.Id2Etag[t.Id] = s.etag(st.version)
+		} else {
+			resp.Id2Error[t.Id] = "TASK_NOT_FOUND"
+		}
+	}
+	for _, d := range body.Delete {
+		if !s.deleteTask(d.ProjectId, d.TaskId) {
+			resp.Id2Error[d.TaskId] = "TASK_NOT_FOUND"
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) batchTaskParent(w http.ResponseWriter, r *http.Request) {
+	var body []struct {
+		ParentId  string `json:"parentId"`
+		ProjectId string `json:"projectId"`
+		TaskId    string `json:"taskId"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := newBatchResponse()
+	for _, b := range body {
+		child, parent := s.findTask(b.TaskId), s.findTask(b.ParentId)
+		switch {
+		case child == nil:
+			resp.Id2Error[b.TaskId] = "TASK_NOT_FOUND"
+		case parent == nil:
+			resp.Id2Error[b.TaskId] = "PARENT_NOT_FOUND"
+		case parent.task.ProjectId != child.task.ProjectId:
+			resp.Id2Error[b.TaskId] = "NOT_IN_THE_SAME_PROJECT"
+		default:
+			s.version++
+			child.task.ParentId, child.version = b.ParentId, s.version
+			resp.Id2Etag[b.TaskId] = s.etag(s.version)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) batchTaskProject(w http.ResponseWriter, r *http.Request) {
+	var body []struct {
+		FromProjectId string `json:"fromProjectId"`
+		TaskId        string `json:"taskId"`
+		ToProjectId   string `json:"toProjectId"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := newBatchResponse()
+	for _, b := range body {
+		st := s.findTask(b.TaskId)
+		switch {
+		case st == nil || st.task.ProjectId != b.FromProjectId:
+			resp.Id2Error[b.TaskId] = "TASK_NOT_FOUND"
+		case !s.hasProject(b.ToProjectId):
+			resp.Id2Error[b.TaskId] = "PROJECT_NOT_FOUND"
+		default:
+			s.version++
+			// a moved subtask leaves its parent
+			st.task.ProjectId, st.task.ParentId, st.version = b.ToProjectId, "", s.version
+			resp.Id2Etag[b.TaskId] = s.etag(s.version)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
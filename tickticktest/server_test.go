@@ -0,0 +1,197 @@
+package tickticktest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ticktick "github.com/ziyixi/go-ticktick"
+	"github.com/ziyixi/go-ticktick/tickticktest"
+)
+
+func newClient(t *testing.T) (*tickticktest.Server, *ticktick.Client) {
+	srv := tickticktest.NewServer("testuser", "testpass")
+	t.Cleanup(srv.Close)
+	srv.AddProject("work")
+	srv.AddProject("home")
+	client, err := ticktick.NewClientWithOptions(srv.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, client
+}
+
+func TestTaskFlow(t *testing.T) {
+	assert := assert.New(t)
+	srv, client := newClient(t)
+
+	// projects
+	projects, err := client.ListProjects()
+	assert.Nil(err)
+	assert.Len(projects, 2)
+
+	// create
+	task, err := ticktick.NewTask(client, "write report", "the weekly one", time.Time{}, "work")
+	assert.Nil(err)
+	task.Tags = []string{"urgent"}
+	task.SetDue(time.Date(2023, 1, 6, 17, 0, 0, 0, time.UTC), false)
+	task, err = client.CreateTask(task)
+	assert.Nil(err)
+	assert.Len(task.Id, 24)
+	assert.Equal("work", task.ProjectName)
+
+	// search
+	tasks, err := client.QueryTasks(ticktick.Query().InProjects("work").WithAnyTag("urgent"))
+	assert.Nil(err)
+	if assert.Len(tasks, 1) {
+		assert.Equal(task.Id, tasks[0].Id)
+		assert.Equal("2023-01-06T17:00:00.000+0000", tasks[0].DueDate.String())
+	}
+
+	// move
+	task, err = client.MoveTask(task, "home")
+	assert.Nil(err)
+	assert.Equal("home", task.ProjectName)
+	tasks, _ = client.QueryTasks(ticktick.Query().InProjects("home"))
+	assert.Len(tasks, 1)
+
+	// subtask, moved to the project of the parent
+	child, _ := ticktick.NewTask(client, "collect numbers", "", time.Time{}, "work")
+	child, err = client.CreateTask(child)
+	assert.Nil(err)
+	parent, child, err := client.MakeSubtask(task, child)
+	assert.Nil(err)
+	assert.Equal(task.Id, child.ParentId)
+	assert.Equal(parent.ProjectId, child.ProjectId)
+
+	// update
+	child.Title = "collect the numbers"
+	child, err = client.UpdateTask(child)
+	assert.Nil(err)
+	stored, _ := srv.Task(child.Id)
+	assert.Equal("collect the numbers", stored.Title)
+	assert.Equal(task.Id, stored.ParentId)
+
+	// complete, the task leaves the synced tasks
+	_, err = client.CompleteTask(parent)
+	assert.Nil(err)
+	tasks, _ = client.QueryTasks(ticktick.Query())
+	assert.Equal([]string{child.Id}, taskIds(tasks))
+	stored, _ = srv.Task(parent.Id)
+	assert.Equal(ticktick.Completed, stored.Status)
+	assert.False(stored.CompletedTime.IsZero())
+
+	// delete
+	_, err = client.DeleteTask(child)
+	assert.Nil(err)
+	tasks, _ = client.QueryTasks(ticktick.Query())
+	assert.Empty(tasks)
+	_, ok := srv.Task(child.Id)
+	assert.False(ok)
+	assert.Len(srv.Tasks(), 1)
+}
+
+func TestBatchTasks(t *testing.T) {
+	assert := assert.New(t)
+	srv, client := newClient(t)
+	existing := srv.AddTask(ticktick.TaskItem{Title: "made in the app"})
+
+	// normal case
+	result, err := client.BatchTasks(ticktick.BatchRequest{
+		Add:    []ticktick.TaskItem{{Title: "a"}, {Title: "b", ProjectId: "random"}},
+		Update: []ticktick.TaskItem{{Id: "random", Title: "c"}},
+	})
+	assert.Nil(err)
+	assert.Nil(result.Added[0].Err)
+	assert.NotNil(result.Added[1].Err)
+	var itemErr *ticktick.ItemError
+	if assert.True(errors.As(result.Updated[0].Err, &itemErr)) {
+		assert.Equal("TASK_NOT_FOUND", itemErr.Message)
+	}
+
+	tasks, _ := client.QueryTasks(ticktick.Query().Sorted(ticktick.ByTitle))
+	assert.Equal([]string{"a", "made in the app"}, taskTitles(tasks))
+	assert.Equal(srv.InboxId(), tasks[0].ProjectId)
+
+	// delete
+	result, err = client.BatchTasks(ticktick.BatchRequest{Delete: []ticktick.TaskItem{existing}})
+	assert.Nil(err)
+	assert.Nil(result.Deleted[0].Err)
+	tasks, _ = client.QueryTasks(ticktick.Query())
+	assert.Equal([]string{"a"}, taskTitles(tasks))
+}
+
+func TestMissingTask(t *testing.T) {
+	assert := assert.New(t)
+	_, client := newClient(t)
+	parent, _ := ticktick.NewTask(client, "parent", "", time.Time{}, "work")
+	parent, err := client.CreateTask(parent)
+	assert.Nil(err)
+	missing := *parent
+	missing.Id = "000000000000000000000fff"
+	missing.Title = "never created"
+
+	// the batch endpoints answer 200 with the error of the item
+	var itemErr *ticktick.ItemError
+	_, err = client.MoveTask(&missing, "home")
+	if assert.True(errors.As(err, &itemErr)) {
+		assert.Equal(missing.Id, itemErr.Id)
+		assert.Equal("TASK_NOT_FOUND", itemErr.Message)
+	}
+	_, _, err = client.MakeSubtask(parent, &missing)
+	if assert.True(errors.As(err, &itemErr)) {
+		assert.Equal(missing.Id, itemErr.Id)
+		assert.Equal("TASK_NOT_FOUND", itemErr.Message)
+	}
+
+	tasks, _ := client.QueryTasks(ticktick.Query())
+	assert.Equal([]string{parent.Id}, taskIds(tasks))
+}
+
+func TestSignIn(t *testing.T) {
+	assert := assert.New(t)
+	srv, client := newClient(t)
+
+	// the client signs in again when the token is revoked
+	srv.RevokeToken()
+	srv.AddTask(ticktick.TaskItem{Title: "new"})
+	tasks, err := client.QueryTasks(ticktick.Query())
+	assert.Nil(err)
+	assert.Equal([]string{"new"}, taskTitles(tasks))
+
+	// wrong password
+	_, err = ticktick.NewClientWithOptions(
+		ticktick.WithCredentials("testuser", "wrong"),
+		ticktick.WithBaseURL(srv.BaseURL()),
+		ticktick.WithRetryPolicy(ticktick.RetryPolicy{MaxAttempts: 1}),
+	)
+	var apiErr *ticktick.APIError
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(http.StatusUnauthorized, apiErr.StatusCode)
+		assert.Equal("username_password_not_match", apiErr.ErrorCode)
+	}
+
+	// endpoints that are not implemented
+	resp, err := http.Get(srv.BaseURL() + "/project/all/closed")
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func taskIds(tasks []ticktick.TaskItem) []string {
+	var ids []string
+	for _, t := range tasks {
+		ids = append(ids, t.Id)
+	}
+	return ids
+}
+
+func taskTitles(tasks []ticktick.TaskItem) []string {
+	var titles []string
+	for _, t := range tasks {
+		titles = append(titles, t.Title)
+	}
+	return titles
+}
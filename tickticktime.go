@@ -0,0 +1,150 @@
+package ticktick
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// the layout of the api with any offset
+const tickTickTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// TickTickTime is a time of the api, sent as "2006-01-02T15:04:05.000+0000" in UTC.
+// The zero value is an unset time, sent as null.
+type TickTickTime struct {
+	time.Time
+}
+
+// wrap t, converted to UTC
+func NewTickTickTime(t time.Time) TickTickTime {
+	if t.IsZero() {
+		return TickTickTime{}
+	}
+	return TickTickTime{t.UTC()}
+}
+
+// parse a time in the format of the api, an empty string is the unset time.
+// A date alone, like "2023-01-02", is its midnight in UTC.
+func ParseTickTickTime(s string) (TickTickTime, error) {
+	if s == "" {
+		return TickTickTime{}, nil
+	}
+	t, err := time.Parse(tickTickTimeLayout, s)
+	if err != nil {
+		// some endpoints use the standard format or a date
+		var otherErr error
+		if t, otherErr = time.Parse(time.RFC3339Nano, s); otherErr != nil {
+			if t, otherErr = time.Parse(time.DateOnly, s); otherErr != nil {
+				return TickTickTime{}, fmt.Errorf("invalid time %q: %w", s, err)
+			}
+		}
+	}
+	return NewTickTickTime(t), nil
+}
+
+// the time in the format of the api, "" if unset
+func (t TickTickTime) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(TemplateTime)
+}
+
+func (t TickTickTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+func (t *TickTickTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*t = TickTickTime{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTickTickTime(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// the location of TimeZone, nil if it is empty or unknown
+func (t *TaskItem) Location() *time.Location {
+	if t.TimeZone == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// StartDate in loc, the zero time if unset. An all day task starts at
+// the midnight of its date in loc, whatever its TimeZone is. A nil loc is
+// time.Local, like the Location of a task without TimeZone.
+func (t *TaskItem) StartIn(loc *time.Location) time.Time {
+	return t.dateIn(t.StartDate, loc)
+}
+
+// DueDate in loc, the zero time if unset. An all day task is due at
+// the midnight of its date in loc, whatever its TimeZone is. A nil loc is time.Local.
+func (t *TaskItem) DueIn(loc *time.Location) time.Time {
+	return t.dateIn(t.DueDate, loc)
+}
+
+func (t *TaskItem) dateIn(date TickTickTime, loc *time.Location) time.Time {
+	if date.IsZero() {
+		return time.Time{}
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if !t.IsAllDay {
+		return date.In(loc)
+	}
+	// the server keeps the all day dates as the midnight in the task time zone
+	taskLoc := t.Location()
+	if taskLoc == nil {
+		taskLoc = loc
+	}
+	y, m, d := date.In(taskLoc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// Set the start date. For an all day task only the date of start in its location
+// counts. TimeZone is set to the location of start, unless it is time.Local.
+func (t *TaskItem) SetStart(start time.Time, allDay bool) {
+	t.StartDate = t.prepareDate(start, allDay)
+}
+
+// Set the due date like SetStart, the start date is moved to the due date
+// if it is unset or later.
+func (t *TaskItem) SetDue(due time.Time, allDay bool) {
+	t.DueDate = t.prepareDate(due, allDay)
+	if t.StartDate.IsZero() || t.StartDate.After(t.DueDate.Time) {
+		t.StartDate = t.DueDate
+	}
+}
+
+func (t *TaskItem) prepareDate(date time.Time, allDay bool) TickTickTime {
+	if date.IsZero() {
+		return TickTickTime{}
+	}
+	t.IsAllDay = allDay
+	if loc := date.Location(); loc != time.Local {
+		t.TimeZone = loc.String()
+	}
+	if allDay {
+		y, m, d := date.Date()
+		date = time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	}
+	return NewTickTickTime(date)
+}
@@ -0,0 +1,115 @@
+package ticktick
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func MustParseTickTickTime(s string) TickTickTime {
+	t, err := ParseTickTickTime(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestTickTickTimeJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	// round trip
+	var tt TickTickTime
+	assert.Nil(json.Unmarshal([]byte(`"2023-01-02T15:04:05.000+0000"`), &tt))
+	assert.Equal(time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), tt.Time)
+	data, err := json.Marshal(tt)
+	assert.Nil(err)
+	assert.Equal(`"2023-01-02T15:04:05.000+0000"`, string(data))
+
+	// other offsets and formats are converted to UTC
+	assert.Nil(json.Unmarshal([]byte(`"2023-01-02T15:04:05.000+0800"`), &tt))
+	assert.Equal("2023-01-02T07:04:05.000+0000", tt.String())
+	assert.Nil(json.Unmarshal([]byte(`"2023-01-02T15:04:05Z"`), &tt))
+	assert.Equal("2023-01-02T15:04:05.000+0000", tt.String())
+	assert.Nil(json.Unmarshal([]byte(`"2023-01-02"`), &tt))
+	assert.Equal("2023-01-02T00:00:00.000+0000", tt.String())
+
+	// unset
+	for _, raw := range []string{`null`, `""`} {
+		tt = NewTickTickTime(time.Now())
+		assert.Nil(json.Unmarshal([]byte(raw), &tt))
+		assert.True(tt.IsZero())
+	}
+	data, _ = json.Marshal(TickTickTime{})
+	assert.Equal("null", string(data))
+	assert.Equal("", TickTickTime{}.String())
+
+	// invalid
+	assert.NotNil(json.Unmarshal([]byte(`"yesterday"`), &tt))
+	assert.NotNil(json.Unmarshal([]byte(`12`), &tt))
+	_, err = ParseTickTickTime("yesterday")
+	assert.NotNil(err)
+
+	// inside a task
+	var task TaskItem
+	assert.Nil(json.Unmarshal([]byte(`{"startDate": "2023-01-02T15:04:05.000+0000", "dueDate": null}`), &task))
+	assert.Equal(2023, task.StartDate.Year())
+	assert.True(task.DueDate.IsZero())
+}
+
+func TestTaskDates(t *testing.T) {
+	assert := assert.New(t)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	// timed tasks are just converted
+	task := TaskItem{}
+	due := time.Date(2023, 1, 2, 9, 30, 0, 0, shanghai)
+	task.SetDue(due, false)
+	assert.Equal("2023-01-02T01:30:00.000+0000", task.DueDate.String())
+	assert.Equal(task.DueDate, task.StartDate)
+	assert.Equal("Asia/Shanghai", task.TimeZone)
+	assert.False(task.IsAllDay)
+	assert.True(task.DueIn(newYork).Equal(due))
+	assert.Equal(newYork, task.DueIn(newYork).Location())
+
+	// all day tasks keep their date in every location
+	task = TaskItem{}
+	task.SetDue(due, true)
+	assert.True(task.IsAllDay)
+	assert.Equal("2023-01-01T16:00:00.000+0000", task.DueDate.String())
+	assert.Equal(time.Date(2023, 1, 2, 0, 0, 0, 0, newYork), task.DueIn(newYork))
+	assert.Equal(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), task.StartIn(time.UTC))
+
+	// an earlier start is kept, a later one moves to the due date
+	task = TaskItem{}
+	task.SetStart(time.Date(2023, 1, 1, 8, 0, 0, 0, time.UTC), false)
+	task.SetDue(time.Date(2023, 1, 3, 8, 0, 0, 0, time.UTC), false)
+	assert.Equal("2023-01-01T08:00:00.000+0000", task.StartDate.String())
+	task.SetStart(time.Date(2023, 1, 5, 8, 0, 0, 0, time.UTC), false)
+	task.SetDue(time.Date(2023, 1, 4, 8, 0, 0, 0, time.UTC), false)
+	assert.Equal("2023-01-04T08:00:00.000+0000", task.StartDate.String())
+	assert.Equal("UTC", task.TimeZone)
+
+	// the local location does not override the time zone
+	task.SetDue(time.Date(2023, 1, 4, 8, 0, 0, 0, time.Local), false)
+	assert.Equal("UTC", task.TimeZone)
+
+	// unset dates and unknown time zones
+	task = TaskItem{IsAllDay: true, TimeZone: "Random/Zone", DueDate: MustParseTickTickTime("2023-01-01T16:00:00.000+0000")}
+	assert.True(task.StartIn(time.UTC).IsZero())
+	assert.Nil(task.Location())
+	assert.Equal(time.Date(2023, 1, 2, 0, 0, 0, 0, shanghai), task.DueIn(shanghai))
+	task.SetDue(time.Time{}, false)
+	assert.True(task.DueDate.IsZero())
+
+	// a nil location is time.Local, for the tasks without time zone
+	task = TaskItem{DueDate: MustParseTickTickTime("2023-01-01T16:00:00.000+0000")}
+	assert.Nil(task.Location())
+	assert.Equal(time.Date(2023, 1, 1, 16, 0, 0, 0, time.UTC).In(time.Local), task.DueIn(task.Location()))
+	task.IsAllDay = true
+	y, m, d := task.DueDate.In(time.Local).Date()
+	assert.Equal(time.Date(y, m, d, 0, 0, 0, 0, time.Local), task.DueIn(nil))
+	assert.True(task.StartIn(nil).IsZero())
+}
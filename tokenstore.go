@@ -0,0 +1,119 @@
+package ticktick
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenStore persists login tokens, so that new clients can skip the sign in.
+// Load returns an empty token and no error when nothing is stored for the key.
+type TokenStore interface {
+	Load(key string) (string, error)
+	Save(key, token string) error
+}
+
+// MemoryTokenStore keeps the tokens in memory, it is safe for concurrent use
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]string)}
+}
+
+func (s *MemoryTokenStore) Load(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[key], nil
+}
+
+func (s *MemoryTokenStore) Save(key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token == "" {
+		delete(s.tokens, key)
+	} else {
+		s.tokens[key] = token
+	}
+	return nil
+}
+
+// FileTokenStore keeps the tokens in a json file only readable by the current user
+type FileTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) Load(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	return tokens[key], nil
+}
+
+func (s *FileTokenStore) Save(key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		delete(tokens, key)
+	} else {
+		tokens[key] = token
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	// write to a temporary file of its own first, so that neither a crash nor another process
+	// saving at the same time leaves a broken store
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o600)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *FileTokenStore) read() (map[string]string, error) {
+	tokens := make(map[string]string)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("token store %v is corrupted: %w", s.path, err)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,158 @@
+package ticktick
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryTokenStore(t *testing.T) {
+	assert := assert.New(t)
+	store := NewMemoryTokenStore()
+
+	token, err := store.Load("key")
+	assert.Nil(err)
+	assert.Empty(token)
+
+	assert.Nil(store.Save("key", "token"))
+	token, err = store.Load("key")
+	assert.Nil(err)
+	assert.Equal("token", token)
+
+	// saving an empty token removes it
+	assert.Nil(store.Save("key", ""))
+	token, err = store.Load("key")
+	assert.Nil(err)
+	assert.Empty(token)
+}
+
+func TestFileTokenStore(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "nested", "tokens.json")
+	store := NewFileTokenStore(path)
+
+	// missing file
+	token, err := store.Load("key")
+	assert.Nil(err)
+	assert.Empty(token)
+
+	assert.Nil(store.Save("key", "token"))
+	assert.Nil(store.Save("other", "othertoken"))
+	info, err := os.Stat(path)
+	if assert.Nil(err) {
+		assert.Equal(os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// a new store on the same file sees the tokens
+	token, err = NewFileTokenStore(path).Load("key")
+	assert.Nil(err)
+	assert.Equal("token", token)
+
+	assert.Nil(store.Save("key", ""))
+	token, err = store.Load("key")
+	assert.Nil(err)
+	assert.Empty(token)
+	token, err = store.Load("other")
+	assert.Nil(err)
+	assert.Equal("othertoken", token)
+
+	// stores of other processes saving at the same time never leave a broken file
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(NewFileTokenStore(path).Save(fmt.Sprint("key", i), strings.Repeat("t", 1000*i)))
+		}()
+	}
+	wg.Wait()
+	_, err = store.Load("key")
+	assert.Nil(err)
+	files, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(files, 1)
+
+	// corrupted file
+	assert.Nil(os.WriteFile(path, []byte("not json"), 0o600))
+	_, err = store.Load("key")
+	if assert.NotNil(err) {
+		assert.Contains(fmt.Sprint(err), "corrupted")
+	}
+	assert.NotNil(store.Save("key", "token"))
+}
+
+func TestNewClientWithTokenStore(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+	store := NewMemoryTokenStore()
+
+	// the first client signs in and saves the token
+	NewSignInTestServer()
+	NewSyncTestServer(BuildSyncResponse())
+	client, err := NewClientWithOptions(WithCredentials("testuser", "testpass"), WithServer("test"), WithTokenStore(store))
+	assert.Nil(err)
+	assert.NotNil(client)
+	token, _ := store.Load(baseUrlV2Test + "#testuser")
+	assert.Equal("testtoken", token)
+	assert.True(gock.IsDone())
+
+	// the second client reuses the token without signing in
+	NewSyncTestServer(BuildSyncResponse())
+	client, err = NewClientWithOptions(WithCredentials("testuser", "testpass"), WithServer("test"), WithTokenStore(store))
+	assert.Nil(err)
+	assert.NotNil(client)
+	assert.True(gock.IsDone())
+
+	// a rejected token makes the client sign in again
+	store.Save(baseUrlV2Test+"#testuser", "expiredtoken")
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		MatchHeader("Cookie", "t=expiredtoken").
+		Reply(401)
+	NewSignInTestServer()
+	NewSyncTestServer(BuildSyncResponse())
+	client, err = NewClientWithOptions(WithCredentials("testuser", "testpass"), WithServer("test"), WithTokenStore(store))
+	assert.Nil(err)
+	assert.NotNil(client)
+	token, _ = store.Load(baseUrlV2Test + "#testuser")
+	assert.Equal("testtoken", token)
+	assert.True(gock.IsDone())
+
+	// other errors are not hidden by a new sign in
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(500)
+	client, err = NewClientWithOptions(WithCredentials("testuser", "testpass"), WithServer("test"), WithTokenStore(store))
+	assert.NotNil(err)
+	assert.Nil(client)
+
+	// nil store
+	_, err = NewClientWithOptions(WithTokenStore(nil))
+	assert.NotNil(err)
+}
+
+func TestNewClientFromToken(t *testing.T) {
+	defer gock.Off()
+	assert := assert.New(t)
+
+	// normal case
+	NewSyncTestServer(BuildSyncResponse())
+	client, err := NewClientFromToken("testtoken", "test")
+	assert.Nil(err)
+	if assert.NotNil(client) {
+		assert.Equal("pid1", client.projectName2Id["pname1"])
+	}
+
+	// a rejected token without credentials to sign in again
+	gock.New(baseUrlV2Test).
+		Get(fmt.Sprintf(queryUnfinishedJobUrlEndpoint, 0)).
+		Reply(401)
+	client, err = NewClientFromToken("expiredtoken", "test")
+	assert.NotNil(err)
+	assert.Nil(client)
+}
@@ -1,5 +1,12 @@
 package ticktick
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
 const (
 	TemplateTime = "2006-01-02T15:04:05.000+0000"
 )
@@ -13,3 +20,12 @@ func Contains[T comparable](s []T, e T) bool {
 	}
 	return false
 }
+
+// generate an id in the format of the server ids (a mongodb ObjectId),
+// the batch endpoints expect the client to choose the ids of new items
+func newObjectId() string {
+	var b [12]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(time.Now().Unix()))
+	rand.Read(b[4:])
+	return hex.EncodeToString(b[:])
+}
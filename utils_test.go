@@ -16,3 +16,10 @@ func TestContains(t *testing.T) {
 	assert.True(Contains([]int64{123, 456}, 123))
 	assert.False(Contains([]int64{123, 456}, 1233))
 }
+
+func TestNewObjectId(t *testing.T) {
+	assert := assert.New(t)
+	id := newObjectId()
+	assert.Regexp("^[0-9a-f]{24}$", id)
+	assert.NotEqual(id, newObjectId())
+}